@@ -0,0 +1,235 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Apply applies the file's hunks to orig, the content of the original file,
+// and returns the content of the new file. Context and removed lines are
+// checked against orig at the line numbers given by the diff; on a mismatch
+// the error names the hunk and the first line that differs. New files apply
+// to an empty orig and deleted files produce an empty result.
+func (f *DiffFile) Apply(orig string) (string, error) {
+	result, err := f.ApplyWithOptions(orig, ApplyOptions{})
+	if err != nil {
+		return "", err
+	}
+	return result.Content, nil
+}
+
+// ApplyOptions configures how ApplyWithOptions places hunks in a file that
+// has drifted since the diff was taken.
+type ApplyOptions struct {
+	// MaxOffset is the number of lines a hunk may be moved up or down from
+	// where the diff places it. Later hunks are expected to have moved by
+	// the same amount as the hunk before them.
+	MaxOffset int
+
+	// Fuzz is the number of context lines at the start and end of a hunk
+	// that may be ignored if they don't match, as with patch's fuzz factor.
+	Fuzz int
+
+	// BestEffort rejects hunks that cannot be placed, rather than failing
+	// the whole file.
+	BestEffort bool
+}
+
+// ApplyResult is the outcome of ApplyWithOptions.
+type ApplyResult struct {
+	// Content is the content of the new file.
+	Content string
+
+	// Offsets holds, for each hunk, the number of lines it was moved from
+	// where the diff placed it. Rejected hunks have an offset of 0.
+	Offsets []int
+
+	// Rejects lists the hunks that could not be applied in best effort
+	// mode.
+	Rejects []HunkReject
+}
+
+// HunkReject records a hunk that could not be applied.
+type HunkReject struct {
+	// Hunk is the index of the hunk in the file's Hunks.
+	Hunk int
+
+	// Reason describes why the hunk could not be placed.
+	Reason string
+}
+
+// ApplyWithOptions is like Apply, but may move hunks to where they match in
+// a file that has drifted, and may reject hunks rather than fail.
+func (f *DiffFile) ApplyWithOptions(orig string, opts ApplyOptions) (*ApplyResult, error) {
+	if f.isCombined() {
+		return nil, errors.New("cannot apply a combined diff")
+	}
+
+	result := &ApplyResult{Offsets: make([]int, len(f.Hunks))}
+	origLines, origNoNewline := splitLines(orig)
+	var newLines []string
+	var pos int // index of the next unread line of orig
+	var offset int
+	var reachedEnd bool
+	for i, h := range f.Hunks {
+		lines, start, lead, err := h.locate(origLines, pos, offset, opts)
+		if err != nil {
+			if !opts.BestEffort {
+				return nil, fmt.Errorf("hunk %d: %v", i+1, err)
+			}
+			result.Rejects = append(result.Rejects, HunkReject{Hunk: i, Reason: err.Error()})
+			continue
+		}
+		offset = start - lead - h.origIndex()
+		result.Offsets[i] = offset
+
+		newLines = append(newLines, origLines[pos:start]...)
+		pos = start
+		for _, l := range lines {
+			if l.Mode != REMOVED {
+				newLines = append(newLines, l.Content)
+			}
+			if l.Mode != ADDED {
+				pos++
+			}
+		}
+		reachedEnd = pos == len(origLines)
+	}
+
+	// The diff only says whether the new file ends in a newline if it
+	// reaches the end of the file. Otherwise the original ending is kept.
+	newNoNewline := origNoNewline
+	if reachedEnd {
+		newNoNewline = f.NewNoNewlineAtEOF
+	}
+	newLines = append(newLines, origLines[pos:]...)
+	result.Content = joinLines(newLines, newNoNewline)
+	return result, nil
+}
+
+// locate finds where the hunk applies to origLines, starting no earlier than
+// pos. It first tries every offset within opts.MaxOffset of the expected
+// position with all context lines, then ignores more leading and trailing
+// context up to opts.Fuzz. It returns the lines of the hunk that matched,
+// the index in origLines where they start and the number of leading context
+// lines ignored. If the hunk doesn't apply, the error describes the mismatch
+// at the expected position.
+func (h *DiffHunk) locate(origLines []string, pos, offset int, opts ApplyOptions) ([]*DiffLine, int, int, error) {
+	lines := h.WholeRange.Lines
+	expected := h.origIndex() + offset
+	for fuzz := 0; fuzz <= opts.Fuzz; fuzz++ {
+		lead, trail := 0, 0
+		for lead < fuzz && lead < len(lines) && lines[lead].Mode == UNCHANGED {
+			lead++
+		}
+		for trail < fuzz && trail < len(lines)-lead && lines[len(lines)-1-trail].Mode == UNCHANGED {
+			trail++
+		}
+		if fuzz > 0 && lead+trail == 0 {
+			break
+		}
+		trimmed := lines[lead : len(lines)-trail]
+
+		for d := 0; d <= opts.MaxOffset; d++ {
+			for _, start := range []int{expected + lead + d, expected + lead - d} {
+				if start >= pos && matchLines(trimmed, origLines, start) == nil {
+					return trimmed, start, lead, nil
+				}
+				if d == 0 {
+					break
+				}
+			}
+		}
+	}
+
+	if expected < pos || expected > len(origLines) {
+		return nil, 0, 0, fmt.Errorf("line %d is out of range", expected+1)
+	}
+	return nil, 0, 0, matchLines(lines, origLines, expected)
+}
+
+// matchLines checks that the context and removed lines in lines match
+// origLines from index start, returning an error naming the first line that
+// differs.
+func matchLines(lines []*DiffLine, origLines []string, start int) error {
+	if start < 0 || start > len(origLines) {
+		return fmt.Errorf("line %d is out of range", start+1)
+	}
+	pos := start
+	for _, l := range lines {
+		if l.Mode == ADDED {
+			continue
+		}
+		if pos >= len(origLines) {
+			return fmt.Errorf("line %d: expected %q, found end of file", pos+1, l.Content)
+		}
+		if origLines[pos] != l.Content {
+			return fmt.Errorf("line %d: expected %q, found %q", pos+1, l.Content, origLines[pos])
+		}
+		pos++
+	}
+	return nil
+}
+
+// origIndex returns the index into the original file's lines at which the
+// hunk starts.
+func (h *DiffHunk) origIndex() int {
+	if len(h.OrigRange.Lines) > 0 {
+		return h.OrigRange.Lines[0].Number - 1
+	}
+	// A hunk that only adds lines starts after the line given in its
+	// header.
+	return h.OrigRange.Start
+}
+
+// splitLines splits content into lines, reporting whether the last line is
+// missing its newline.
+func splitLines(content string) ([]string, bool) {
+	if content == "" {
+		return nil, false
+	}
+	noNewline := !strings.HasSuffix(content, "\n")
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), noNewline
+}
+
+// Apply applies the diff to files, a map of path to content of the original
+// files, and returns the map of path to content after the change. Deleted
+// files are left out of the result, renamed files are moved to their new path
+// and copied files are added at their new path, keeping the original. Files
+// the diff doesn't touch are copied over unchanged.
+func (d *Diff) Apply(files map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(files))
+	for path, content := range files {
+		result[path] = content
+	}
+
+	for _, f := range d.Files {
+		var orig string
+		if f.Mode != NEW {
+			var ok bool
+			if orig, ok = files[f.OrigName]; !ok {
+				return nil, fmt.Errorf("%s: file not found", f.OrigName)
+			}
+			if f.Mode != COPIED {
+				delete(result, f.OrigName)
+			}
+		}
+
+		content, err := f.Apply(orig)
+		if err != nil {
+			name := f.NewName
+			if name == "" {
+				name = f.OrigName
+			}
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if f.Mode != DELETED {
+			result[f.NewName] = content
+		}
+	}
+	return result, nil
+}
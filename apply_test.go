@@ -0,0 +1,190 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// exampleFiles holds the original files example.diff applies to.
+var exampleFiles = map[string]string{
+	"file1":   "some\nlines\nin\nfile1\n",
+	"file2":   "other\nlines\nin\nfile2\n",
+	"file3":   "still\nmore\nin\nfile3",
+	"symlink": "symlink-destination",
+}
+
+func TestApply(t *testing.T) {
+	diff := setup(t)
+
+	result, err := diff.Apply(exampleFiles)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"file1":   "add a line\nsome\nlines\nfile1\n",
+		"file4":   "added new file",
+		"newname": "other\nlines\nin\nfile2\n",
+	}, result)
+
+	// The input is left untouched.
+	require.Len(t, exampleFiles, 4)
+}
+
+func TestFileApply(t *testing.T) {
+	diff, err := Parse(`diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -2,3 +2,3 @@
+ b
+-c
++C
+ d
+@@ -7,0 +8,2 @@
++h1
++h2
+@@ -10,2 +11 @@
+ j
+-k
+\ No newline at end of file
+`)
+	require.NoError(t, err)
+	file := diff.Files[0]
+
+	content, err := file.Apply("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk")
+	require.NoError(t, err)
+	require.Equal(t, "a\nb\nC\nd\ne\nf\ng\nh1\nh2\nh\ni\nj\n", content)
+
+	_, err = file.Apply("a\nb\nx\nd\ne\nf\ng\nh\ni\nj\nk")
+	require.EqualError(t, err, `hunk 1: line 3: expected "c", found "x"`)
+
+	_, err = file.Apply("a\nb\nc\nd\ne\nf\ng\nh\ni\nj")
+	require.EqualError(t, err, `hunk 3: line 11: expected "k", found end of file`)
+}
+
+func TestFileApplyKeepsEnding(t *testing.T) {
+	diff, err := Parse(`diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -1,2 +1,2 @@
+-a
++A
+ b
+`)
+	require.NoError(t, err)
+	file := diff.Files[0]
+
+	// The hunk doesn't reach the end, so the original ending is kept.
+	content, err := file.Apply("a\nb\nc")
+	require.NoError(t, err)
+	require.Equal(t, "A\nb\nc", content)
+
+	content, err = file.Apply("a\nb\n")
+	require.NoError(t, err)
+	require.Equal(t, "A\nb\n", content)
+}
+
+func TestApplyMissingFile(t *testing.T) {
+	diff := setup(t)
+	_, err := diff.Apply(map[string]string{})
+	require.EqualError(t, err, "file1: file not found")
+
+	files := map[string]string{}
+	for path, content := range exampleFiles {
+		files[path] = content
+	}
+	files["file2"] = "changed\n"
+	_, err = diff.Apply(files)
+	require.EqualError(t, err, `file2: hunk 1: line 1: expected "other", found "changed"`)
+}
+
+func TestApplyWithOffset(t *testing.T) {
+	diff, err := Parse(`diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -2,3 +2,3 @@
+ b
+-c
++C
+ d
+@@ -8,3 +8,3 @@
+ h
+-i
++I
+ j
+`)
+	require.NoError(t, err)
+	file := diff.Files[0]
+	drifted := "new1\nnew2\na\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+
+	_, err = file.Apply(drifted)
+	require.EqualError(t, err, `hunk 1: line 2: expected "b", found "new2"`)
+
+	_, err = file.ApplyWithOptions(drifted, ApplyOptions{MaxOffset: 1})
+	require.EqualError(t, err, `hunk 1: line 2: expected "b", found "new2"`)
+
+	result, err := file.ApplyWithOptions(drifted, ApplyOptions{MaxOffset: 2})
+	require.NoError(t, err)
+	require.Equal(t, "new1\nnew2\na\nb\nC\nd\ne\nf\ng\nh\nI\nj\n", result.Content)
+	require.Equal(t, []int{2, 2}, result.Offsets)
+	require.Empty(t, result.Rejects)
+
+	// Lines removed before the hunk move it up.
+	result, err = file.ApplyWithOptions("b\nc\nd\ne\nf\ng\nh\ni\nj\n", ApplyOptions{MaxOffset: 1})
+	require.NoError(t, err)
+	require.Equal(t, "b\nC\nd\ne\nf\ng\nh\nI\nj\n", result.Content)
+	require.Equal(t, []int{-1, -1}, result.Offsets)
+}
+
+func TestApplyWithFuzz(t *testing.T) {
+	diff, err := Parse(`diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -1,5 +1,5 @@
+ a
+ b
+-c
++C
+ d
+ e
+`)
+	require.NoError(t, err)
+	file := diff.Files[0]
+
+	_, err = file.ApplyWithOptions("x\nb\nc\nd\ny\n", ApplyOptions{})
+	require.EqualError(t, err, `hunk 1: line 1: expected "a", found "x"`)
+
+	result, err := file.ApplyWithOptions("x\nb\nc\nd\ny\n", ApplyOptions{Fuzz: 1})
+	require.NoError(t, err)
+	require.Equal(t, "x\nb\nC\nd\ny\n", result.Content)
+	require.Equal(t, []int{0}, result.Offsets)
+}
+
+func TestApplyBestEffort(t *testing.T) {
+	diff, err := Parse(`diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -1,2 +1,2 @@
+-a
++A
+ b
+@@ -4,2 +4,2 @@
+ d
+-e
++E
+`)
+	require.NoError(t, err)
+	file := diff.Files[0]
+
+	_, err = file.ApplyWithOptions("x\nb\nc\nd\ne\n", ApplyOptions{})
+	require.EqualError(t, err, `hunk 1: line 1: expected "a", found "x"`)
+
+	result, err := file.ApplyWithOptions("x\nb\nc\nd\ne\n", ApplyOptions{BestEffort: true})
+	require.NoError(t, err)
+	require.Equal(t, "x\nb\nc\nd\nE\n", result.Content)
+	require.Equal(t, []HunkReject{{
+		Hunk:   0,
+		Reason: `line 1: expected "a", found "x"`,
+	}}, result.Rejects)
+}
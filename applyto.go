@@ -0,0 +1,103 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ApplyTo applies the diff to the original files in fsys, as Apply does, and
+// writes the files it changes to the directory out: new and modified files
+// are written, creating directories as needed, deleted files are removed,
+// renamed files are written under their new name and removed under the old
+// one, and copied files are written under their new name, leaving the
+// original. Files the diff doesn't touch aren't copied. To patch a directory in
+// place, pass os.DirFS(out) as fsys.
+//
+// Every file is applied before anything is written, so out is left as it
+// was if a file fails to apply; the error names the file. Binary files can't
+// be applied. Files are written with the permission bits of their new mode,
+// if the diff gives one, or else those of the original file, or else 0644.
+func (d *Diff) ApplyTo(fsys fs.FS, out string) error {
+	files := make(map[string]string)
+	perms := make(map[string]fs.FileMode)
+	for _, f := range d.Files {
+		for _, name := range []string{f.OrigName, f.NewName} {
+			if name != "" && name != devNull && !fs.ValidPath(name) {
+				return fmt.Errorf("%s: invalid path", name)
+			}
+		}
+		if f.IsBinary {
+			name := f.NewName
+			if f.Mode == DELETED {
+				name = f.OrigName
+			}
+			return fmt.Errorf("%s: cannot apply a binary diff", name)
+		}
+		if f.Mode == NEW {
+			continue
+		}
+		if _, ok := files[f.OrigName]; ok {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, f.OrigName)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Apply reports the missing file.
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.OrigName, err)
+		}
+		files[f.OrigName] = string(content)
+		if info, err := fs.Stat(fsys, f.OrigName); err == nil {
+			perms[f.OrigName] = info.Mode().Perm()
+		}
+	}
+
+	result, err := d.Apply(files)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range d.Files {
+		if f.Mode != DELETED && f.Mode != RENAMED {
+			continue
+		}
+		if _, ok := result[f.OrigName]; ok {
+			continue
+		}
+		err := os.Remove(filepath.Join(out, filepath.FromSlash(f.OrigName)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%s: %w", f.OrigName, err)
+		}
+	}
+	for _, f := range d.Files {
+		content, ok := result[f.NewName]
+		if f.Mode == DELETED || !ok {
+			continue
+		}
+		perm := fs.FileMode(0644)
+		if p, ok := perms[f.OrigName]; ok {
+			perm = p
+		}
+		if f.NewMode&0777 != 0 {
+			perm = fs.FileMode(f.NewMode & 0777)
+		}
+		path := filepath.Join(out, filepath.FromSlash(f.NewName))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("%s: %w", f.NewName, err)
+		}
+		if err := os.WriteFile(path, []byte(content), perm); err != nil {
+			return fmt.Errorf("%s: %w", f.NewName, err)
+		}
+		if err := os.Chmod(path, perm); err != nil {
+			return fmt.Errorf("%s: %w", f.NewName, err)
+		}
+	}
+	return nil
+}
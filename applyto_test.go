@@ -0,0 +1,147 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func exampleFS() fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for path, content := range exampleFiles {
+		fsys[path] = &fstest.MapFile{Data: []byte(content), Mode: 0600}
+	}
+	return fsys
+}
+
+// readDir returns the content of each file under dir by its slash path.
+func readDir(t *testing.T, dir string) map[string]string {
+	files := map[string]string{}
+	err := fs.WalkDir(os.DirFS(dir), ".", func(path string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(filepath.Join(dir, path))
+		files[path] = string(b)
+		return err
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func TestApplyTo(t *testing.T) {
+	diff := setup(t)
+	out := t.TempDir()
+	require.NoError(t, diff.ApplyTo(exampleFS(), out))
+	require.Equal(t, map[string]string{
+		"file1":   "add a line\nsome\nlines\nfile1\n",
+		"file4":   "added new file",
+		"newname": "other\nlines\nin\nfile2\n",
+	}, readDir(t, out))
+
+	// Modified files keep their permissions and new files get those of
+	// their mode header.
+	info, err := os.Stat(filepath.Join(out, "file1"))
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(filepath.Join(out, "file4"))
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0644), info.Mode().Perm())
+}
+
+func TestApplyToInPlace(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"old.go":   "a\nb\nc\n",
+		"gone.txt": "x\n",
+		"keep.txt": "k\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+	diff, err := Parse(`diff --git a/old.go b/pkg/new.go
+similarity index 70%
+rename from old.go
+rename to pkg/new.go
+index 1111111..2222222
+--- a/old.go
++++ b/pkg/new.go
+@@ -2 +2 @@
+-b
++B
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index 3333333..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-x
+diff --git a/run.sh b/run.sh
+new file mode 100755
+index 0000000..4444444
+--- /dev/null
++++ b/run.sh
+@@ -0,0 +1 @@
++echo hi
+`)
+	require.NoError(t, err)
+	require.NoError(t, diff.ApplyTo(os.DirFS(dir), dir))
+	require.Equal(t, map[string]string{
+		"pkg/new.go": "a\nB\nc\n",
+		"keep.txt":   "k\n",
+		"run.sh":     "echo hi\n",
+	}, readDir(t, dir))
+	info, err := os.Stat(filepath.Join(dir, "run.sh"))
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0755), info.Mode().Perm())
+}
+
+func TestApplyToFailure(t *testing.T) {
+	diff := setup(t)
+	fsys := exampleFS()
+	fsys["file2"] = &fstest.MapFile{Data: []byte("changed\n")}
+	out := t.TempDir()
+	err := diff.ApplyTo(fsys, out)
+	require.EqualError(t, err, `file2: hunk 1: line 1: expected "other", found "changed"`)
+	require.Empty(t, readDir(t, out))
+
+	delete(fsys, "file1")
+	require.EqualError(t, diff.ApplyTo(fsys, out), "file1: file not found")
+
+	binary, err := Parse("diff --git a/img.png b/img.png\nindex 1111111..2222222 100644\nBinary files a/img.png and b/img.png differ\n")
+	require.NoError(t, err)
+	require.EqualError(t, binary.ApplyTo(fsys, out), "img.png: cannot apply a binary diff")
+
+	escape := &Diff{Files: []*DiffFile{{Mode: NEW, NewName: "../x"}}}
+	require.EqualError(t, escape.ApplyTo(fsys, out), "../x: invalid path")
+	require.Empty(t, readDir(t, out))
+}
+
+func TestApplyToCopy(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\nfunc f() {}\n"), 0644))
+	diff, err := Parse(`diff --git a/a.go b/b.go
+similarity index 50%
+copy from a.go
+copy to b.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/b.go
+@@ -1 +1 @@
+-package a
++package b
+`)
+	require.NoError(t, err)
+	require.NoError(t, diff.ApplyTo(os.DirFS(dir), dir))
+	// The file copied from is left where it was.
+	require.Equal(t, map[string]string{
+		"a.go": "package a\nfunc f() {}\n",
+		"b.go": "package b\nfunc f() {}\n",
+	}, readDir(t, dir))
+}
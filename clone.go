@@ -0,0 +1,69 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+// Clone returns a deep copy of the diff. Files, hunks, ranges and lines are
+// all copied, so the clone can be mutated without affecting d.
+func (d *Diff) Clone() *Diff {
+	if d == nil {
+		return nil
+	}
+	c := *d
+	c.Files = nil
+	for _, f := range d.Files {
+		c.Files = append(c.Files, f.Clone())
+	}
+	return &c
+}
+
+// Clone returns a deep copy of the file, as Diff.Clone copies each file.
+func (f *DiffFile) Clone() *DiffFile {
+	if f == nil {
+		return nil
+	}
+	c := *f
+	c.ExtendedHeaders = append([]string(nil), f.ExtendedHeaders...)
+	c.Hunks = nil
+	for _, h := range f.Hunks {
+		c.Hunks = append(c.Hunks, h.Clone())
+	}
+	return &c
+}
+
+// Clone returns a deep copy of the hunk. A line shared by the hunk's ranges,
+// such as an unchanged line in both NewRange and WholeRange, is copied once
+// and stays shared in the clone.
+func (h *DiffHunk) Clone() *DiffHunk {
+	if h == nil {
+		return nil
+	}
+	// WholeRange shares its lines with OrigRange and NewRange. Track the
+	// copies so the clone keeps the same sharing.
+	lines := make(map[*DiffLine]*DiffLine)
+	c := *h
+	c.OrigRange = h.OrigRange.clone(lines)
+	c.NewRange = h.NewRange.clone(lines)
+	c.WholeRange = h.WholeRange.clone(lines)
+	c.ParentRanges = nil
+	for _, r := range h.ParentRanges {
+		c.ParentRanges = append(c.ParentRanges, r.clone(lines))
+	}
+	return &c
+}
+
+func (r DiffRange) clone(lines map[*DiffLine]*DiffLine) DiffRange {
+	c := r
+	c.Lines = nil
+	for _, l := range r.Lines {
+		cl, ok := lines[l]
+		if !ok {
+			line := *l
+			line.ParentModes = append([]DiffLineMode(nil), l.ParentModes...)
+			cl = &line
+			lines[l] = cl
+		}
+		c.Lines = append(c.Lines, cl)
+	}
+	return c
+}
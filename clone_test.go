@@ -0,0 +1,64 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClone(t *testing.T) {
+	diff := setup(t)
+	clone := diff.Clone()
+	require.Equal(t, diff, clone)
+
+	hunk := clone.Files[0].Hunks[0]
+	hunk.NewRange.Lines[0].Content = "changed"
+	hunk.OrigRange.Lines = hunk.OrigRange.Lines[:1]
+	clone.Files[0].NewName = "changed"
+	clone.Files = clone.Files[:1]
+
+	require.Len(t, diff.Files, 6)
+	require.Equal(t, "file1", diff.Files[0].NewName)
+	orig := diff.Files[0].Hunks[0]
+	require.Equal(t, "add a line", orig.NewRange.Lines[0].Content)
+	require.Equal(t, "add a line", orig.WholeRange.Lines[0].Content)
+	require.Len(t, orig.OrigRange.Lines, 4)
+
+	// Lines shared between WholeRange and NewRange stay shared in the clone.
+	require.Equal(t, "changed", hunk.WholeRange.Lines[0].Content)
+}
+
+func TestCloneFileAndHunk(t *testing.T) {
+	diff := setup(t)
+	file := diff.Files[0]
+	fileClone := file.Clone()
+	require.Equal(t, file, fileClone)
+	fileClone.ExtendedHeaders[0] = "changed"
+	fileClone.Hunks = nil
+	require.Equal(t, "index 504d2a1..50ccec3 100644", file.ExtendedHeaders[0])
+	require.Len(t, file.Hunks, 1)
+
+	hunk := file.Hunks[0]
+	clone := hunk.Clone()
+	require.Equal(t, hunk, clone)
+
+	// "some" is unchanged: NewRange and WholeRange share one line, and
+	// OrigRange has a copy of its own. The clone is laid out the same way.
+	require.True(t, clone.WholeRange.Lines[1] == clone.NewRange.Lines[1])
+	require.True(t, clone.OrigRange.Lines[0] != clone.NewRange.Lines[1])
+	require.True(t, clone.WholeRange.Lines[1] != hunk.WholeRange.Lines[1])
+
+	clone.WholeRange.Lines[1].Content = "changed"
+	require.Equal(t, "changed", clone.NewRange.Lines[1].Content)
+	require.Equal(t, "some", clone.OrigRange.Lines[0].Content)
+	require.Equal(t, "some", hunk.WholeRange.Lines[1].Content)
+	require.Equal(t, "some", hunk.NewRange.Lines[1].Content)
+
+	var nilFile *DiffFile
+	require.Nil(t, nilFile.Clone())
+	var nilHunk *DiffHunk
+	require.Nil(t, nilHunk.Clone())
+}
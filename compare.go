@@ -0,0 +1,342 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import "strings"
+
+// defaultContext is the number of unchanged lines git shows around each
+// change.
+const defaultContext = 3
+
+// CompareOption configures how Compare builds a diff.
+type CompareOption func(*compareOptions)
+
+type compareOptions struct {
+	context int
+}
+
+// WithContext sets the number of unchanged lines shown around each change,
+// like git's -U flag. Changes separated by no more than twice as many lines
+// share a hunk. With 0 each run of changes gets a hunk of its own. The
+// default is 3; negative values are treated as 0.
+func WithContext(lines int) CompareOption {
+	return func(o *compareOptions) {
+		o.context = max(lines, 0)
+	}
+}
+
+// Compare computes the diff between orig and changed, the content of the file
+// filename before and after a change, in the form "git diff" would show it.
+// The result holds a single DiffFile. If orig is empty the file is treated as
+// new, and if changed is empty as deleted.
+func Compare(orig, changed, filename string, opts ...CompareOption) *Diff {
+	o := compareOptions{context: defaultContext}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	origLines, origNoNewline := splitLines(orig)
+	newLines, newNoNewline := splitLines(changed)
+
+	file := &DiffFile{
+		Mode:     MODIFIED,
+		OrigName: filename,
+		NewName:  filename,
+	}
+	switch {
+	case orig == "" && changed != "":
+		file.Mode = NEW
+		file.OrigName = ""
+		file.NewMode = 0100644
+	case changed == "" && orig != "":
+		file.Mode = DELETED
+		file.NewName = ""
+		file.OldMode = 0100644
+	}
+	file.Command = "diff --git a/" + filename + " b/" + filename
+	file.DiffHeader = file.Command
+
+	// A last line without a newline differs from the same text with one.
+	equal := func(i, j int) bool {
+		return origLines[i] == newLines[j] &&
+			(origNoNewline && i == len(origLines)-1) == (newNoNewline && j == len(newLines)-1)
+	}
+	edits := myers(len(origLines), len(newLines), equal)
+
+	var position int
+	for _, hunkEdits := range groupEdits(edits, o.context) {
+		first := hunkEdits[0]
+		hunk := &DiffHunk{
+			HunkHeader: funcContext(origLines[:first.orig]),
+			OrigRange:  DiffRange{Start: first.orig},
+			NewRange:   DiffRange{Start: first.new},
+		}
+		if len(file.Hunks) > 0 {
+			// The hunk header takes a position.
+			position++
+		}
+		file.Hunks = append(file.Hunks, hunk)
+
+		for _, e := range hunkEdits {
+			position++
+			switch e.op {
+			case editEqual:
+				origLine := &DiffLine{Mode: UNCHANGED, Number: e.orig + 1, Content: origLines[e.orig], Position: position}
+				newLine := &DiffLine{Mode: UNCHANGED, Number: e.new + 1, Content: newLines[e.new], Position: position}
+				hunk.OrigRange.Lines = append(hunk.OrigRange.Lines, origLine)
+				hunk.NewRange.Lines = append(hunk.NewRange.Lines, newLine)
+				hunk.WholeRange.Lines = append(hunk.WholeRange.Lines, newLine)
+			case editDelete:
+				line := &DiffLine{Mode: REMOVED, Number: e.orig + 1, Content: origLines[e.orig], Position: position}
+				hunk.OrigRange.Lines = append(hunk.OrigRange.Lines, line)
+				hunk.WholeRange.Lines = append(hunk.WholeRange.Lines, line)
+			case editInsert:
+				line := &DiffLine{Mode: ADDED, Number: e.new + 1, Content: newLines[e.new], Position: position}
+				hunk.NewRange.Lines = append(hunk.NewRange.Lines, line)
+				hunk.WholeRange.Lines = append(hunk.WholeRange.Lines, line)
+			}
+
+			// A no-newline marker follows the last line of a side
+			// missing its newline, and takes a position.
+			origEnd := e.op != editInsert && origNoNewline && e.orig == len(origLines)-1
+			newEnd := e.op != editDelete && newNoNewline && e.new == len(newLines)-1
+			if origEnd {
+				file.OrigNoNewlineAtEOF = true
+			}
+			if newEnd {
+				file.NewNoNewlineAtEOF = true
+			}
+			if origEnd || newEnd {
+				position++
+			}
+		}
+
+		// Like git, a range with lines starts at its first line and an
+		// empty range at the line before it.
+		hunk.OrigRange.Length = len(hunk.OrigRange.Lines)
+		if hunk.OrigRange.Length > 0 {
+			hunk.OrigRange.Start = hunk.OrigRange.Lines[0].Number
+		}
+		hunk.NewRange.Length = len(hunk.NewRange.Lines)
+		if hunk.NewRange.Length > 0 {
+			hunk.NewRange.Start = hunk.NewRange.Lines[0].Number
+		}
+	}
+
+	diff := &Diff{Files: []*DiffFile{file}}
+	diff.Raw = diff.String()
+	return diff
+}
+
+// funcContext returns the line git shows after a hunk's range when it has no
+// diff driver for the file: the last of the lines before the hunk starting
+// with a letter, "_" or "$", cut to 80 bytes.
+func funcContext(before []string) string {
+	for i := len(before) - 1; i >= 0; i-- {
+		l := before[i]
+		if l == "" {
+			continue
+		}
+		if c := l[0]; c == '_' || c == '$' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
+			if len(l) > 80 {
+				l = l[:80]
+			}
+			return strings.TrimRight(l, " \t\r\v\f")
+		}
+	}
+	return ""
+}
+
+type editOp int
+
+const (
+	editEqual editOp = iota
+	editDelete
+	editInsert
+)
+
+// edit is a step in turning the original lines into the new ones. orig and
+// new are the indexes of the lines in each, or of the next line on the side
+// the edit doesn't touch.
+type edit struct {
+	op        editOp
+	orig, new int
+}
+
+// myers returns the shortest edit script turning n original lines into m
+// new ones, using the linear space form of Myers' O(ND) algorithm, which
+// finds the middle of an optimal path and recurses on either side of it.
+// Within each run of changes the deletions come before the insertions, as in
+// a unified diff.
+func myers(n, m int, equal func(i, j int) bool) []edit {
+	s := &editScript{equal: equal}
+	s.compare(0, n, 0, m)
+	edits := s.edits
+
+	// Move deletions ahead of insertions in each run of changes.
+	for start := 0; start < len(edits); {
+		if edits[start].op == editEqual {
+			start++
+			continue
+		}
+		end := start
+		for end < len(edits) && edits[end].op != editEqual {
+			end++
+		}
+		run := append([]edit(nil), edits[start:end]...)
+		i := start
+		for _, op := range []editOp{editDelete, editInsert} {
+			for _, e := range run {
+				if e.op == op {
+					edits[i] = e
+					i++
+				}
+			}
+		}
+		start = end
+	}
+	return edits
+}
+
+// editScript builds the edits myers returns.
+type editScript struct {
+	equal func(i, j int) bool
+	edits []edit
+
+	// forward and backward hold, per diagonal, the furthest point reached
+	// from each end by middleSnake. They are reused by each call.
+	forward, backward []int
+}
+
+// compare appends the edits turning the original lines [x0, x1) into the new
+// lines [y0, y1).
+func (s *editScript) compare(x0, x1, y0, y1 int) {
+	for x0 < x1 && y0 < y1 && s.equal(x0, y0) {
+		s.edits = append(s.edits, edit{op: editEqual, orig: x0, new: y0})
+		x0++
+		y0++
+	}
+	var suffix int
+	for x1 > x0 && y1 > y0 && s.equal(x1-1, y1-1) {
+		x1--
+		y1--
+		suffix++
+	}
+
+	switch {
+	case x0 == x1:
+		for y := y0; y < y1; y++ {
+			s.edits = append(s.edits, edit{op: editInsert, orig: x0, new: y})
+		}
+	case y0 == y1:
+		for x := x0; x < x1; x++ {
+			s.edits = append(s.edits, edit{op: editDelete, orig: x, new: y0})
+		}
+	default:
+		// With the ends trimmed, at least two edits are needed, so both
+		// halves are smaller than the whole.
+		x, y, u, v := s.middleSnake(x0, x1, y0, y1)
+		s.compare(x0, x, y0, y)
+		for ; x < u; x, y = x+1, y+1 {
+			s.edits = append(s.edits, edit{op: editEqual, orig: x, new: y})
+		}
+		s.compare(u, x1, v, y1)
+	}
+
+	for i := range suffix {
+		s.edits = append(s.edits, edit{op: editEqual, orig: x1 + i, new: y1 + i})
+	}
+}
+
+// middleSnake returns the run of equal lines, from (x, y) to (u, v), in the
+// middle of a shortest edit script turning [x0, x1) into [y0, y1), found by
+// searching from both ends at once until the paths meet.
+func (s *editScript) middleSnake(x0, x1, y0, y1 int) (x, y, u, v int) {
+	n, m := x1-x0, y1-y0
+	delta := n - m
+	odd := delta%2 != 0
+
+	// Diagonal k, on which x-y == k, is at offset+k. The backward search
+	// runs on the reversed lines, where its diagonal k is delta-k forward.
+	offset := (n+m+1)/2 + 1
+	size := 2*offset + 1
+	if cap(s.forward) < size {
+		s.forward = make([]int, size)
+		s.backward = make([]int, size)
+	}
+	forward, backward := s.forward[:size], s.backward[:size]
+	clear(forward)
+	clear(backward)
+
+	for d := 0; d <= (n+m+1)/2; d++ {
+		// Diagonals outside the grid can't be reached in d edits.
+		kmin, kmax := -d+2*max(0, d-m), d-2*max(0, d-n)
+
+		for k := kmin; k <= kmax; k += 2 {
+			var px int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				px = forward[offset+k+1]
+			} else {
+				px = forward[offset+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < m && s.equal(x0+px, y0+py) {
+				px++
+				py++
+			}
+			forward[offset+k] = px
+			if kb := delta - k; odd && kb >= -(d-1) && kb <= d-1 && px+backward[offset+kb] >= n {
+				return x0 + sx, y0 + sy, x0 + px, y0 + py
+			}
+		}
+
+		for k := kmin; k <= kmax; k += 2 {
+			var px int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				px = backward[offset+k+1]
+			} else {
+				px = backward[offset+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < m && s.equal(x1-1-px, y1-1-py) {
+				px++
+				py++
+			}
+			backward[offset+k] = px
+			if kf := delta - k; !odd && kf >= -d && kf <= d && px+forward[offset+kf] >= n {
+				return x1 - px, y1 - py, x1 - sx, y1 - sy
+			}
+		}
+	}
+	// The searches always meet by the time each has made half the edits.
+	panic("diffparser: no middle snake")
+}
+
+// groupEdits splits edits into hunks, each holding a run of changes with up
+// to context unchanged lines either side. Changes separated by no more than
+// twice the context share a hunk.
+func groupEdits(edits []edit, context int) [][]edit {
+	var hunks [][]edit
+	var start, end int // the edits of the current hunk
+	inHunk := false
+	for i, e := range edits {
+		if e.op == editEqual {
+			continue
+		}
+		if inHunk && i-end <= 2*context {
+			end = i + 1
+			continue
+		}
+		if inHunk {
+			hunks = append(hunks, edits[start:min(end+context, len(edits))])
+		}
+		start, end, inHunk = max(i-context, 0), i+1, true
+	}
+	if inHunk {
+		hunks = append(hunks, edits[start:min(end+context, len(edits))])
+	}
+	return hunks
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The expected diffs are the output of "git diff --no-index", less the
+// "index" line.
+func TestCompare(t *testing.T) {
+	for _, test := range []struct {
+		about         string
+		orig, changed string
+		expected      string
+	}{{
+		about:   "changes far apart get their own hunks",
+		orig:    "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\n",
+		changed: "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\nthirteen\n",
+		expected: `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -10,3 +10,4 @@ nine
+ ten
+ eleven
+ twelve
++thirteen
+`,
+	}, {
+		about:   "missing newlines at the end",
+		orig:    "one\ntwo\nthree",
+		changed: "one\ntwo\nthree\nfour",
+		expected: `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1,3 +1,4 @@
+ one
+ two
+-three
+\ No newline at end of file
++three
++four
+\ No newline at end of file
+`,
+	}, {
+		about:   "new file",
+		changed: "one\ntwo\n",
+		expected: `diff --git a/f.txt b/f.txt
+new file mode 100644
+--- /dev/null
++++ b/f.txt
+@@ -0,0 +1,2 @@
++one
++two
+`,
+	}, {
+		about: "deleted file",
+		orig:  "one\n",
+		expected: `diff --git a/f.txt b/f.txt
+deleted file mode 100644
+--- a/f.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-one
+`,
+	}} {
+		t.Run(test.about, func(t *testing.T) {
+			diff := Compare(test.orig, test.changed, "f.txt")
+			require.Equal(t, test.expected, diff.String())
+			require.Equal(t, test.expected, diff.Raw)
+
+			parsed, err := Parse(diff.Raw)
+			require.NoError(t, err)
+			requireEquivalent(t, diff, parsed)
+
+			content, err := diff.Files[0].Apply(test.orig)
+			require.NoError(t, err)
+			require.Equal(t, test.changed, content)
+		})
+	}
+}
+
+func TestCompareWithContext(t *testing.T) {
+	orig := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	changed := "a\nB\nc\nd\ne\nF\ng\nh\nx\ni\nj\n"
+	header := "diff --git a/f.txt b/f.txt\n--- a/f.txt\n+++ b/f.txt\n"
+	for _, test := range []struct {
+		context  int
+		expected string
+	}{{
+		context: 0,
+		expected: `@@ -2 +2 @@ a
+-b
++B
+@@ -6 +6 @@ e
+-f
++F
+@@ -8,0 +9 @@ h
++x
+`,
+	}, {
+		context: 1,
+		expected: `@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+@@ -5,5 +5,6 @@ d
+ e
+-f
++F
+ g
+ h
++x
+ i
+`,
+	}, {
+		context: 3,
+		expected: `@@ -1,10 +1,11 @@
+ a
+-b
++B
+ c
+ d
+ e
+-f
++F
+ g
+ h
++x
+ i
+ j
+`,
+	}} {
+		t.Run(strconv.Itoa(test.context), func(t *testing.T) {
+			diff := Compare(orig, changed, "f.txt", WithContext(test.context))
+			require.Equal(t, header+test.expected, diff.String())
+
+			parsed, err := Parse(diff.Raw)
+			require.NoError(t, err)
+			requireEquivalent(t, diff, parsed)
+
+			content, err := diff.Files[0].Apply(orig)
+			require.NoError(t, err)
+			require.Equal(t, changed, content)
+		})
+	}
+
+	require.Equal(t, Compare(orig, changed, "f.txt").String(), Compare(orig, changed, "f.txt", WithContext(3)).String())
+	require.Equal(t, Compare(orig, changed, "f.txt", WithContext(0)).String(), Compare(orig, changed, "f.txt", WithContext(-1)).String())
+}
+
+func TestCompareUnchanged(t *testing.T) {
+	diff := Compare("", "", "f.txt")
+	require.Empty(t, diff.Files[0].Hunks)
+
+	diff = Compare("one\n", "one\n", "f.txt")
+	require.Len(t, diff.Files, 1)
+	require.Equal(t, MODIFIED, diff.Files[0].Mode)
+	require.Empty(t, diff.Files[0].Hunks)
+	require.Equal(t, "diff --git a/f.txt b/f.txt\n", diff.Raw)
+}
+
+func TestCompareLineNumbers(t *testing.T) {
+	diff := Compare("a\nb\nc\n", "a\nx\nc\nd\n", "f.txt")
+	require.Len(t, diff.Files, 1)
+	file := diff.Files[0]
+	require.Equal(t, MODIFIED, file.Mode)
+	require.Equal(t, "f.txt", file.OrigName)
+	require.Equal(t, "f.txt", file.NewName)
+	require.Len(t, file.Hunks, 1)
+
+	hunk := file.Hunks[0]
+	require.Equal(t, DiffRange{Start: 1, Length: 3, Lines: hunk.OrigRange.Lines}, hunk.OrigRange)
+	require.Equal(t, DiffRange{Start: 1, Length: 4, Lines: hunk.NewRange.Lines}, hunk.NewRange)
+	var lines []DiffLine
+	for _, l := range hunk.WholeRange.Lines {
+		lines = append(lines, *l)
+	}
+	require.Equal(t, []DiffLine{
+		{Mode: UNCHANGED, Number: 1, Content: "a", Position: 1},
+		{Mode: REMOVED, Number: 2, Content: "b", Position: 2},
+		{Mode: ADDED, Number: 2, Content: "x", Position: 3},
+		{Mode: UNCHANGED, Number: 3, Content: "c", Position: 4},
+		{Mode: ADDED, Number: 4, Content: "d", Position: 5},
+	}, lines)
+}
+
+func TestFuncContext(t *testing.T) {
+	require.Equal(t, "", funcContext(nil))
+	require.Equal(t, "", funcContext([]string{"  indented", "{", ""}))
+	require.Equal(t, "func main() {", funcContext([]string{"package main", "func main() {  ", "\tx := 1"}))
+	long := "func " + string(make([]byte, 100))
+	require.Len(t, funcContext([]string{long}), 80)
+}
+
+func TestMyers(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 2000; i++ {
+		a := make([]byte, rng.IntN(12))
+		b := make([]byte, rng.IntN(12))
+		for _, s := range [][]byte{a, b} {
+			for j := range s {
+				s[j] = 'a' + byte(rng.IntN(3))
+			}
+		}
+		edits := myers(len(a), len(b), func(i, j int) bool { return a[i] == b[j] })
+
+		// The edits turn a into b, with no more changes than needed.
+		var got []byte
+		var x, changes int
+		for _, e := range edits {
+			switch e.op {
+			case editEqual:
+				require.Equal(t, x, e.orig)
+				require.Equal(t, a[e.orig], b[e.new])
+				got = append(got, a[e.orig])
+				x++
+			case editDelete:
+				require.Equal(t, x, e.orig)
+				x++
+				changes++
+			case editInsert:
+				got = append(got, b[e.new])
+				changes++
+			}
+		}
+		require.Equal(t, len(a), x, "%s %s", a, b)
+		require.Equal(t, string(b), string(got), "%s %s", a, b)
+		require.Equal(t, len(a)+len(b)-2*lcsLength(a, b), changes, "%s %s", a, b)
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b.
+func lcsLength(a, b []byte) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// TestMyersSpace checks that comparing long inputs with nothing in common,
+// the worst case for the number of edits, takes memory linear in their size.
+func TestMyersSpace(t *testing.T) {
+	const n = 8000
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	edits := myers(n, n, func(i, j int) bool { return false })
+	runtime.ReadMemStats(&after)
+	require.Len(t, edits, 2*n)
+	// Keeping every step of the search would take gigabytes.
+	allocated := after.TotalAlloc - before.TotalAlloc
+	require.True(t, allocated < n*1024, "%d bytes", allocated)
+}
+
+func BenchmarkCompareDisjoint(b *testing.B) {
+	var orig, changed strings.Builder
+	for i := 0; i < 8000; i++ {
+		fmt.Fprintf(&orig, "a%d\n", i)
+		fmt.Fprintf(&changed, "b%d\n", i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Compare(orig.String(), changed.String(), "f")
+	}
+}
@@ -4,11 +4,16 @@
 package diffparser
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
-
-	"errors"
+	"unsafe"
 )
 
 // FileMode represents the file status in a diff
@@ -21,19 +26,45 @@ const (
 	MODIFIED
 	// NEW if the file is created and there is no diff
 	NEW
+	// RENAMED if the file is renamed. Files whose "---" and "+++" names
+	// differ are treated as renamed even without git's rename headers.
+	RENAMED
+	// COPIED if the file is a copy of OrigName, which is left as it is, as
+	// git shows with "copy from" and "copy to" headers.
+	COPIED
+)
+
+const (
+	devNull               = "/dev/null"
+	newFileModePrefix     = "new file mode "
+	deletedFileModePrefix = "deleted file mode "
+	oldModePrefix         = "old mode "
+	newModePrefix         = "new mode "
+	binaryFilesPrefix     = "Binary files "
+	binaryFilesSuffix     = " differ"
+	hgBinaryPrefix        = "Binary file "
+	hgBinarySuffix        = " has changed"
+	binaryPatch           = "GIT binary patch"
+	similarityPrefix      = "similarity index "
+	renameFromPrefix      = "rename from "
+	renameToPrefix        = "rename to "
+	renamePrefix          = "rename "
+	copyFromPrefix        = "copy from "
+	copyToPrefix          = "copy to "
+	indexPrefix           = "index "
 )
 
 // DiffRange contains the DiffLine's
 type DiffRange struct {
 
 	// starting line number
-	Start int
+	Start int `json:"start"`
 
 	// the number of lines the change diffHunk applies to
-	Length int
+	Length int `json:"length"`
 
 	// Each line of the hunk range.
-	Lines []*DiffLine
+	Lines []*DiffLine `json:"lines"`
 }
 
 // DiffLineMode tells the line if added, removed or unchanged
@@ -50,43 +81,121 @@ const (
 
 // DiffLine is the least part of an actual diff
 type DiffLine struct {
-	Mode     DiffLineMode
-	Number   int
-	Content  string
-	Position int // the line in the diff
+	Mode     DiffLineMode `json:"mode"`
+	Number   int          `json:"number"`
+	Content  string       `json:"content"`
+	Position int          `json:"position"` // the line in the diff, see GitHubPosition
+
+	// ParentModes holds the mode of the line relative to each parent of a
+	// combined diff ("diff --cc"). It is nil for ordinary diffs.
+	ParentModes []DiffLineMode `json:"parentModes,omitempty"`
+
+	// Offset and Length locate the line, from its "+", "-" or " " on, in
+	// the input Parse read, so that Raw[Offset:Offset+Length] is the line as
+	// it was written, without its newline. They are only set with
+	// WithLineOffsets.
+	Offset int `json:"offset,omitempty"`
+	Length int `json:"length,omitempty"`
 }
 
 // DiffHunk is a group of difflines
 type DiffHunk struct {
-	HunkHeader string
-	OrigRange  DiffRange
-	NewRange   DiffRange
-	WholeRange DiffRange
+	HunkHeader string    `json:"hunkHeader"`
+	OrigRange  DiffRange `json:"origRange"`
+	NewRange   DiffRange `json:"newRange"`
+	WholeRange DiffRange `json:"wholeRange"`
+
+	// ParentRanges holds one range per parent of a combined diff ("diff
+	// --cc"). OrigRange is a copy of the first parent's range. It is nil for
+	// ordinary diffs.
+	ParentRanges []DiffRange `json:"parentRanges,omitempty"`
+
+	// RawSpan is where the hunk is in the input Parse read: its "@@" line
+	// through its last line, including a "\ No newline at end of file"
+	// marker and the newline ending it.
+	RawSpan RawSpan `json:"rawSpan"`
+}
+
+// RawSpan is the byte offsets [Start, End) of part of a diff in the input
+// Parse read, which is kept as Diff.Raw, so that Raw[Start:End] is that part
+// as it was written, carriage returns and all. Diffs built from others, such
+// as by Filter or Merge, keep the spans of the files and hunks they were built
+// from, which no longer match their own Raw.
+type RawSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
 }
 
 // DiffFile is the sum of diffhunks and holds the changes of the file features
 type DiffFile struct {
-	DiffHeader string
-	Mode       FileMode
-	OrigName   string
-	NewName    string
-	Hunks      []*DiffHunk
+	DiffHeader string   `json:"diffHeader"`
+	Mode       FileMode `json:"mode"`
+	OrigName   string   `json:"origName"`
+	NewName    string   `json:"newName"`
+
+	// Command is the "diff" line that starts the file, e.g.
+	// "diff --git a/x b/x", as it appears in the diff.
+	Command string `json:"command"`
+
+	// ExtendedHeaders holds git's extended header lines, such as "new file
+	// mode 100644", "similarity index 90%" or "index 1234567..89abcde", in
+	// the order they appear between the "diff" line and the "---" line.
+	// Those Parse doesn't interpret, such as "copy from", are written out
+	// as they are by String.
+	ExtendedHeaders []string `json:"extendedHeaders,omitempty"`
+
+	// Hunks holds the changes to the file's content. Git writes no hunks
+	// for an empty file that is created or deleted; see IsEmpty.
+	Hunks []*DiffHunk `json:"hunks"`
+
+	// IsBinary is set for binary files, which have no hunks, when the diff
+	// has a "Binary files ... differ" line or a "GIT binary patch". It is
+	// independent of Mode, as binary files can be created, deleted,
+	// modified or renamed like any other.
+	IsBinary bool `json:"isBinary"`
+
+	// SimilarityIndex is the percentage of unchanged content, as reported
+	// by git for renamed and copied files, and 0 for others. 100 means the
+	// content is unchanged, so the file has no hunks.
+	SimilarityIndex int `json:"similarityIndex"`
+
+	// OldMode and NewMode are the git file modes, e.g. 0100644, of the
+	// original and new file. They are zero when the diff does not give them.
+	OldMode int `json:"oldMode"`
+	NewMode int `json:"newMode"`
+
+	// OrigNoNewlineAtEOF and NewNoNewlineAtEOF are set when the diff marks
+	// the original or new file as missing a trailing newline.
+	OrigNoNewlineAtEOF bool `json:"origNoNewlineAtEOF"`
+	NewNoNewlineAtEOF  bool `json:"newNoNewlineAtEOF"`
+
+	// RawSpan is where the file is in the input Parse read: its "diff" line
+	// up to the next file's, or for the last file up to the blank lines
+	// kept in Diff.Trailing.
+	RawSpan RawSpan `json:"rawSpan"`
 }
 
 // Diff is the collection of DiffFiles
 type Diff struct {
-	Files []*DiffFile
-	Raw   string `sql:"type:text"`
+	Files []*DiffFile `json:"files"`
+	Raw   string      `json:"raw,omitempty" sql:"type:text"`
 
-	PullID uint `sql:"index"`
+	// Trailing holds the blank lines after the end of the diff, which
+	// String writes back out so that a diff parsed and printed again is
+	// unchanged. Blank lines elsewhere are ignored.
+	Trailing string `json:"trailing,omitempty"`
+
+	PullID uint `json:"-" sql:"index"`
 }
 
 func (d *Diff) addFile(file *DiffFile) {
 	d.Files = append(d.Files, file)
 }
 
-// Changed returns a map of filename to lines changed in that file. Deleted
-// files are ignored.
+// Changed returns a map of filename to the numbers of the lines added to
+// that file, sorted and without duplicates. Files are keyed by NewName, so a
+// renamed file appears under its new name. Binary files have no lines, so
+// map to an empty slice. Deleted files are ignored; see Removed for those.
 func (d *Diff) Changed() map[string][]int {
 	dFiles := make(map[string][]int)
 
@@ -94,25 +203,134 @@ func (d *Diff) Changed() map[string][]int {
 		if f.Mode == DELETED {
 			continue
 		}
+		if f.IsBinary && dFiles[f.NewName] == nil {
+			dFiles[f.NewName] = []int{}
+		}
 
 		for _, h := range f.Hunks {
 			for _, dl := range h.NewRange.Lines {
-				if dl.Mode == ADDED { // TODO(waigani) return removed
+				if dl.Mode == ADDED {
 					dFiles[f.NewName] = append(dFiles[f.NewName], dl.Number)
 				}
 			}
 		}
 	}
 
+	for name, lines := range dFiles {
+		dFiles[name] = sortedUnique(lines)
+	}
 	return dFiles
 }
 
-func regFind(s string, reg string, group int) string {
-	re := regexp.MustCompile(reg)
-	return re.FindStringSubmatch(s)[group]
+// Removed returns a map of filename to the numbers of the lines removed from
+// that file, sorted and without duplicates. The numbers are those of the
+// original file, and files are keyed by OrigName, so a renamed file appears
+// under its old name. Deleted files are included with all of their lines and
+// new files are ignored. Binary files have no lines, so map to an empty
+// slice.
+func (d *Diff) Removed() map[string][]int {
+	dFiles := make(map[string][]int)
+
+	for _, f := range d.Files {
+		if f.Mode == NEW {
+			continue
+		}
+		if f.IsBinary && dFiles[f.OrigName] == nil {
+			dFiles[f.OrigName] = []int{}
+		}
+
+		for _, h := range f.Hunks {
+			for _, dl := range h.OrigRange.Lines {
+				if dl.Mode == REMOVED {
+					dFiles[f.OrigName] = append(dFiles[f.OrigName], dl.Number)
+				}
+			}
+		}
+	}
+
+	for name, lines := range dFiles {
+		dFiles[name] = sortedUnique(lines)
+	}
+	return dFiles
+}
+
+// sortedUnique sorts lines in place and removes duplicates.
+func sortedUnique(lines []int) []int {
+	slices.Sort(lines)
+	return slices.Compact(lines)
 }
 
+// Sort orders the files by NewName and then OrigName, and the hunks within
+// each file by the start of their new range, so that the diff serializes the
+// same way regardless of the order it was built in.
+func (d *Diff) Sort() {
+	sort.SliceStable(d.Files, func(i, j int) bool {
+		a, b := d.Files[i], d.Files[j]
+		if a.NewName != b.NewName {
+			return a.NewName < b.NewName
+		}
+		return a.OrigName < b.OrigName
+	})
+	for _, f := range d.Files {
+		sort.SliceStable(f.Hunks, func(i, j int) bool {
+			return f.Hunks[i].NewRange.Start < f.Hunks[j].NewRange.Start
+		})
+	}
+}
+
+// NetLines returns the number of lines added minus the number of lines
+// removed across all files. New files count all of their lines as added and
+// deleted files all of theirs as removed.
+func (d *Diff) NetLines() int {
+	var net int
+	for _, f := range d.Files {
+		for _, h := range f.Hunks {
+			for _, l := range h.WholeRange.Lines {
+				switch l.Mode {
+				case ADDED:
+					net++
+				case REMOVED:
+					net--
+				}
+			}
+		}
+	}
+	return net
+}
+
+// TotalFiles returns the number of files in the diff.
+func (d *Diff) TotalFiles() int {
+	return len(d.Files)
+}
+
+// TotalHunks returns the number of hunks across all files.
+func (d *Diff) TotalHunks() int {
+	var n int
+	for _, f := range d.Files {
+		n += len(f.Hunks)
+	}
+	return n
+}
+
+// TotalLines returns the number of lines across all hunks, counting each
+// added, removed and unchanged line once. Hunk headers and file headers are
+// not counted.
+func (d *Diff) TotalLines() int {
+	var n int
+	for _, f := range d.Files {
+		for _, h := range f.Hunks {
+			n += len(h.WholeRange.Lines)
+		}
+	}
+	return n
+}
+
+// lineMode returns the mode given by the first character of a hunk line, or
+// ErrInvalidLine if there is none.
 func lineMode(line string) (*DiffLineMode, error) {
+	if line == "" {
+		return nil, fmt.Errorf("%w: empty line", ErrInvalidLine)
+	}
 	var m DiffLineMode
 	switch line[:1] {
 	case " ":
@@ -122,25 +340,225 @@ func lineMode(line string) (*DiffLineMode, error) {
 	case "-":
 		m = REMOVED
 	default:
-		return nil, errors.New("could not parse line mode for line: \"" + line + "\"")
+		return nil, fmt.Errorf("%w: %q", ErrInvalidLine, line)
 	}
 	return &m, nil
 }
 
+// ParseFile reads the diff in the file at path and parses it.
+func ParseFile(path string, opts ...ParseOption) (*Diff, error) {
+	var o parseOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxBytes > 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading diff: %w", err)
+		}
+		if info.Size() > int64(o.maxBytes) {
+			return nil, fmt.Errorf("%s: %w: %d bytes, limit is %d", path, ErrDiffTooLarge, info.Size(), o.maxBytes)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading diff: %w", err)
+	}
+	// b is ours alone, so may be aliased.
+	diff, err := ParseBytes(b, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return diff, nil
+}
+
+// ParseReader reads the diff from r and parses it. With WithMaxBytes or
+// WithMaxLines, it stops reading with ErrDiffTooLarge as soon as the diff
+// is over the limit, so an oversized diff isn't held in memory.
+func ParseReader(r io.Reader, opts ...ParseOption) (*Diff, error) {
+	var o parseOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxBytes > 0 || o.maxLines > 0 {
+		r = &limitReader{r: r, maxBytes: o.maxBytes, maxLines: o.maxLines}
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading diff: %w", err)
+	}
+	// b is ours alone, so may be aliased.
+	return ParseBytes(b, opts...)
+}
+
+// limitReader reads from r until more than maxBytes bytes or maxLines lines
+// have been read, then fails with ErrDiffTooLarge. A zero limit is no limit.
+type limitReader struct {
+	r                  io.Reader
+	maxBytes, maxLines int
+	bytes, lines       int
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.bytes += n
+	l.lines += bytes.Count(p[:n], []byte("\n"))
+	if l.maxBytes > 0 && l.bytes > l.maxBytes {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrDiffTooLarge, l.maxBytes)
+	}
+	// A last line without a newline is caught by Parse.
+	if l.maxLines > 0 && l.lines > l.maxLines {
+		return n, fmt.Errorf("%w: more than %d lines", ErrDiffTooLarge, l.maxLines)
+	}
+	return n, err
+}
+
+// ParseBytes is like Parse, but takes the diff as a byte slice, which it
+// parses where it is rather than copying it into a string first, saving memory
+// for large diffs. Every string in the returned Diff, including Raw, the names
+// and the lines' Content, aliases b, so b must not be modified or reused for as
+// long as the Diff is in use. Callers that can't promise that should call
+// Parse(string(b)).
+func ParseBytes(b []byte, opts ...ParseOption) (*Diff, error) {
+	return Parse(unsafe.String(unsafe.SliceData(b), len(b)), opts...)
+}
+
+// hunkHeaderReg matches a hunk header such as "@@ -1,4 +1,5 @@ func main() {",
+// capturing the original start and length, the new start and length and the
+// section heading. Extra spaces between the ranges are tolerated.
+var hunkHeaderReg = regexp.MustCompile(`@@ +\-(\d+),?(\d+)? +\+(\d+),?(\d+)? *@@ ?(.+)?`)
+
 // Parse takes a diff, such as produced by "git diff", and parses it into a
-// Diff struct.
-func Parse(diffString string) (*Diff, error) {
+// Diff struct. A line it can't read is reported as a *ParseError.
+func Parse(diffString string, opts ...ParseOption) (*Diff, error) {
+	return NewParser(opts...).Parse(diffString)
+}
+
+// Parser parses diffs as Parse does, keeping its options and scratch space
+// between calls so that parsing many diffs allocates less. A Parser must not
+// be used by more than one goroutine at a time.
+type Parser struct {
+	opts parseOptions
+
+	// lines is reused to split each diff into lines.
+	lines []string
+	// starts is reused to hold the offset of each line in the diff.
+	starts []int
+}
+
+// NewParser returns a Parser that parses diffs with opts.
+func NewParser(opts ...ParseOption) *Parser {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(&p.opts)
+	}
+	return p
+}
+
+// Parse parses diffString as Parse does. The Diff returned shares no memory
+// with the Parser, so it stays valid after later calls.
+func (p *Parser) Parse(diffString string) (*Diff, error) {
+	o := &p.opts
+	headerReg := hunkHeaderReg
+	if o.hunkHeaderReg != nil {
+		if n := o.hunkHeaderReg.NumSubexp(); n != hunkHeaderReg.NumSubexp() {
+			return nil, fmt.Errorf("hunk header regexp must have %d capture groups, has %d", hunkHeaderReg.NumSubexp(), n)
+		}
+		headerReg = o.hunkHeaderReg
+	}
+	if o.maxBytes > 0 && len(diffString) > o.maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrDiffTooLarge, len(diffString), o.maxBytes)
+	}
+	if o.maxLines > 0 {
+		n := strings.Count(diffString, "\n")
+		if diffString != "" && !strings.HasSuffix(diffString, "\n") {
+			n++
+		}
+		if n > o.maxLines {
+			return nil, fmt.Errorf("%w: %d lines, limit is %d", ErrDiffTooLarge, n, o.maxLines)
+		}
+	}
+
 	var diff Diff
 	diff.Raw = diffString
-	lines := strings.Split(diffString, "\n")
+	p.lines = splitInto(p.lines[:0], diffString)
+	lines := p.lines
+	defer clear(p.lines)
+	// Take the offsets before any option changes the lines.
+	p.starts = lineStarts(p.starts[:0], lines)
+	starts := p.starts
+	// lineEnd returns the offset just past line i and its newline.
+	lineEnd := func(i int) int {
+		if i+1 < len(starts) {
+			return starts[i+1]
+		}
+		return len(diffString)
+	}
+	// setOffset records where line i, which options may have cut the
+	// start off, is in the input.
+	setOffset := func(line *DiffLine, i int) {
+		if !o.lineOffsets {
+			return
+		}
+		end := lineEnd(i)
+		if i+1 < len(starts) {
+			end--
+		}
+		line.Length = len(lines[i])
+		line.Offset = end - line.Length
+	}
+	if o.stripPrefix != "" {
+		for i, l := range lines {
+			lines[i] = stripPrefix(l, o.stripPrefix)
+		}
+	}
+	if o.stripQuotes {
+		for i, l := range lines {
+			lines[i] = stripQuotes(l)
+		}
+	}
+	if o.detectIndent {
+		if indent := detectIndent(lines); indent != "" {
+			for i, l := range lines {
+				// Lines without the indent are text around the
+				// diff, not part of it.
+				if strings.HasPrefix(l, indent) {
+					lines[i] = l[len(indent):]
+				} else {
+					lines[i] = ""
+				}
+			}
+		}
+	}
+
+	// Size the files and lines up front, allocating the lines together.
+	var files, sourceLines int
+	for _, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "diff "):
+			files++
+		case strings.HasPrefix(l, " "):
+			// Unchanged lines have a copy on each side.
+			sourceLines += 2
+		case strings.HasPrefix(l, "+") || strings.HasPrefix(l, "-"):
+			sourceLines++
+		}
+	}
+	if files > 0 {
+		diff.Files = make([]*DiffFile, 0, files)
+	}
+	arena := make(lineArena, 0, sourceLines)
 
 	var file *DiffFile
 	var hunk *DiffHunk
 	var ADDEDCount int
 	var REMOVEDCount int
 	var inHunk bool
-	oldFilePrefix := "--- a/"
-	newFilePrefix := "+++ b/"
+	var lastLineMode DiffLineMode
+	var parentCounts []int
+	oldFilePrefix := "--- "
+	newFilePrefix := "+++ "
 
 	var diffPosCount int
 	var firstHunkInFile bool
@@ -151,37 +569,162 @@ func Parse(diffString string) (*Diff, error) {
 		case strings.HasPrefix(l, "diff "):
 			inHunk = false
 
+			if o.maxFiles > 0 && len(diff.Files) >= o.maxFiles {
+				return nil, fmt.Errorf("%w: more than %d files", ErrDiffTooLarge, o.maxFiles)
+			}
+
+			if file != nil {
+				file.RawSpan.End = starts[idx]
+			}
 			// Start a new file.
-			file = &DiffFile{}
+			file = &DiffFile{Command: l, RawSpan: RawSpan{Start: starts[idx]}}
+			// The header is the "diff" line, the "index" line, which
+			// may come after other extended headers such as a mode
+			// change, and the "---" and "+++" lines that follow them.
 			header := l
-			if len(lines) > idx+3 {
-				rein := regexp.MustCompile(`^index .+$`)
-				remp := regexp.MustCompile(`^(-|\+){3} .+$`)
-				index := lines[idx+1]
-				if rein.MatchString(index) {
-					header = header + "\n" + index
-				}
-				mp1 := lines[idx+2]
-				mp2 := lines[idx+3]
-				if remp.MatchString(mp1) && remp.MatchString(mp2) {
-					header = header + "\n" + mp1 + "\n" + mp2
+			j := idx + 1
+			for ; j < len(lines) && isExtendedHeaderLine(lines[j]); j++ {
+				file.ExtendedHeaders = append(file.ExtendedHeaders, lines[j])
+				if strings.HasPrefix(lines[j], indexPrefix) {
+					header += "\n" + lines[j]
 				}
 			}
+			if j+1 < len(lines) && strings.HasPrefix(lines[j], oldFilePrefix) && strings.HasPrefix(lines[j+1], newFilePrefix) {
+				header += "\n" + lines[j] + "\n" + lines[j+1]
+			}
 			file.DiffHeader = header
 			diff.Files = append(diff.Files, file)
 			firstHunkInFile = true
 
 			// File mode.
 			file.Mode = MODIFIED
-		case l == "+++ /dev/null":
-			file.Mode = DELETED
-		case l == "--- /dev/null":
+
+			// Take the names from the "diff" line, for files with no
+			// "---" and "+++" lines such as empty or binary ones.
+			file.OrigName, file.NewName = parseDiffNames(l)
+		case file == nil && (strings.HasPrefix(l, "@@") || (o.hunkHeaderReg != nil && headerReg.MatchString(l))):
+			return nil, newParseError(idx, file, fmt.Errorf("%w: %s", ErrHunkBeforeFile, l))
+		case file == nil && isFileHeaderLine(l):
+			return nil, newParseError(idx, file, fmt.Errorf("%w: %s", ErrHeaderBeforeFile, l))
+		case !inHunk && strings.HasPrefix(l, oldFilePrefix):
+			if name := parseFileName(strings.TrimPrefix(l, oldFilePrefix)); name == devNull {
+				file.Mode = NEW
+			} else {
+				file.OrigName = name
+			}
+		case !inHunk && strings.HasPrefix(l, newFilePrefix):
+			if name := parseFileName(strings.TrimPrefix(l, newFilePrefix)); name == devNull {
+				file.Mode = DELETED
+			} else {
+				file.NewName = name
+			}
+		case !inHunk && strings.HasPrefix(l, newFileModePrefix):
+			mode, err := parseFileMode(l, newFileModePrefix)
+			if err != nil {
+				return nil, newParseError(idx, file, err)
+			}
 			file.Mode = NEW
-		case strings.HasPrefix(l, oldFilePrefix):
-			file.OrigName = strings.TrimPrefix(l, oldFilePrefix)
-		case strings.HasPrefix(l, newFilePrefix):
-			file.NewName = strings.TrimPrefix(l, newFilePrefix)
-		case strings.HasPrefix(l, "@@ "):
+			file.NewMode = mode
+		case !inHunk && strings.HasPrefix(l, deletedFileModePrefix):
+			mode, err := parseFileMode(l, deletedFileModePrefix)
+			if err != nil {
+				return nil, newParseError(idx, file, err)
+			}
+			file.Mode = DELETED
+			file.OldMode = mode
+		case !inHunk && strings.HasPrefix(l, oldModePrefix):
+			// A mode change leaves the file's Mode alone, as it may come
+			// with edits or a rename.
+			mode, err := parseFileMode(l, oldModePrefix)
+			if err != nil {
+				return nil, newParseError(idx, file, err)
+			}
+			file.OldMode = mode
+		case !inHunk && strings.HasPrefix(l, newModePrefix):
+			mode, err := parseFileMode(l, newModePrefix)
+			if err != nil {
+				return nil, newParseError(idx, file, err)
+			}
+			file.NewMode = mode
+		case !inHunk && strings.HasPrefix(l, binaryFilesPrefix) && strings.HasSuffix(l, binaryFilesSuffix):
+			origName, newName, ok := parseBinaryNames(strings.TrimSuffix(strings.TrimPrefix(l, binaryFilesPrefix), binaryFilesSuffix), file.OrigName, file.NewName)
+			if !ok {
+				return nil, newParseError(idx, file, fmt.Errorf("%w: %s", ErrInvalidBinaryDiff, l))
+			}
+			file.IsBinary = true
+			if name := origName; name == devNull {
+				file.Mode = NEW
+			} else if file.OrigName == "" {
+				file.OrigName = name
+			}
+			if name := newName; name == devNull {
+				file.Mode = DELETED
+			} else if file.NewName == "" {
+				file.NewName = name
+			}
+		case !inHunk && l == binaryPatch:
+			file.IsBinary = true
+		case !inHunk && strings.HasPrefix(l, hgBinaryPrefix) && strings.HasSuffix(l, hgBinarySuffix):
+			// Mercurial's binary line names the file once, as the
+			// "diff -r" line does.
+			file.IsBinary = true
+		case !inHunk && strings.HasPrefix(l, similarityPrefix):
+			n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(l, similarityPrefix), "%"))
+			if err != nil || n < 0 || n > 100 {
+				return nil, newParseError(idx, file, fmt.Errorf("%w: %s", ErrInvalidSimilarityIndex, l))
+			}
+			file.SimilarityIndex = n
+		case !inHunk && strings.HasPrefix(l, renameFromPrefix):
+			file.Mode = RENAMED
+			file.OrigName = unquoteFileName(strings.TrimPrefix(l, renameFromPrefix))
+		case !inHunk && strings.HasPrefix(l, renameToPrefix):
+			file.Mode = RENAMED
+			file.NewName = unquoteFileName(strings.TrimPrefix(l, renameToPrefix))
+		case !inHunk && strings.HasPrefix(l, copyFromPrefix):
+			file.Mode = COPIED
+			file.OrigName = unquoteFileName(strings.TrimPrefix(l, copyFromPrefix))
+		case !inHunk && strings.HasPrefix(l, copyToPrefix):
+			file.Mode = COPIED
+			file.NewName = unquoteFileName(strings.TrimPrefix(l, copyToPrefix))
+		case file != nil && !inHunk && strings.HasPrefix(l, renamePrefix):
+			// Older gits summarise a rename on one line, e.g.
+			// "rename src/{a => b}/x.go (90%)".
+			origName, newName, ok := parseRenamePath(strings.TrimPrefix(l, renamePrefix))
+			if !ok {
+				return nil, newParseError(idx, file, fmt.Errorf("%w: %s", ErrInvalidRename, l))
+			}
+			file.Mode = RENAMED
+			file.OrigName = origName
+			file.NewName = newName
+		case strings.HasPrefix(l, "@@@"):
+			if firstHunkInFile {
+				diffPosCount = 0
+				firstHunkInFile = false
+			}
+
+			inHunk = true
+			var err error
+			hunk, err = parseCombinedHunkHeader(l)
+			if err != nil {
+				return nil, newParseError(idx, file, err)
+			}
+			hunk.RawSpan = RawSpan{Start: starts[idx], End: lineEnd(idx)}
+			if o.hunkHeaderFunc != nil {
+				hunk.HunkHeader = o.hunkHeaderFunc(hunk.HunkHeader)
+			}
+			if n := len(file.Hunks); o.strictHunkOrder && n > 0 && !hunkFollows(o.counted(file.Hunks[n-1]), hunk) {
+				return nil, newParseError(idx, file, fmt.Errorf("%w: %s", ErrHunkOutOfOrder, l))
+			}
+			file.Hunks = append(file.Hunks, hunk)
+
+			// (re)set line counts
+			ADDEDCount = hunk.NewRange.Start
+			parentCounts = parentCounts[:0]
+			for _, r := range hunk.ParentRanges {
+				parentCounts = append(parentCounts, r.Start)
+			}
+		case strings.HasPrefix(l, "@@ ") || (o.hunkHeaderReg != nil && (!inHunk || hunk.isComplete()) && headerReg.MatchString(l)):
+			parentCounts = nil
 			if firstHunkInFile {
 				diffPosCount = 0
 				firstHunkInFile = false
@@ -189,40 +732,46 @@ func Parse(diffString string) (*Diff, error) {
 
 			inHunk = true
 			// Start new hunk.
-			hunk = &DiffHunk{}
+			hunk = &DiffHunk{RawSpan: RawSpan{Start: starts[idx], End: lineEnd(idx)}}
 			file.Hunks = append(file.Hunks, hunk)
 
 			// Parse hunk heading for ranges
-			re := regexp.MustCompile(`@@ \-(\d+),?(\d+)? \+(\d+),?(\d+)? @@ ?(.+)?`)
-			m := re.FindStringSubmatch(l)
+			invalid := func() error {
+				return newParseError(idx, file, fmt.Errorf("%w: %s", ErrInvalidHunkHeader, l))
+			}
+			m := headerReg.FindStringSubmatch(l)
 			if len(m) < 5 {
-				return nil, errors.New("Error parsing line: " + l)
+				return nil, invalid()
 			}
 			a, err := strconv.Atoi(m[1])
 			if err != nil {
-				return nil, err
+				return nil, invalid()
 			}
-			b := a
+			// An omitted length means a single line.
+			b := 1
 			if len(m[2]) > 0 {
 				b, err = strconv.Atoi(m[2])
 				if err != nil {
-					return nil, err
+					return nil, invalid()
 				}
 			}
 			c, err := strconv.Atoi(m[3])
 			if err != nil {
-				return nil, err
+				return nil, invalid()
 			}
-			d := c
+			d := 1
 			if len(m[4]) > 0 {
 				d, err = strconv.Atoi(m[4])
 				if err != nil {
-					return nil, err
+					return nil, invalid()
 				}
 			}
 			if len(m[5]) > 0 {
 				hunk.HunkHeader = m[5]
 			}
+			if o.hunkHeaderFunc != nil {
+				hunk.HunkHeader = o.hunkHeaderFunc(hunk.HunkHeader)
+			}
 
 			// hunk orig range.
 			hunk.OrigRange = DiffRange{
@@ -236,53 +785,609 @@ func Parse(diffString string) (*Diff, error) {
 				Length: d,
 			}
 
+			// Make room for the lines the header promises, but no more
+			// than there are left.
+			remaining := len(lines) - idx - 1
+			hunk.OrigRange.Lines = makeLines(min(b, remaining))
+			hunk.NewRange.Lines = makeLines(min(d, remaining))
+			hunk.WholeRange.Lines = makeLines(min(b+d, remaining))
+			if n := len(file.Hunks); o.strictHunkOrder && n > 1 && !hunkFollows(o.counted(file.Hunks[n-2]), hunk) {
+				return nil, newParseError(idx, file, fmt.Errorf("%w: %s", ErrHunkOutOfOrder, l))
+			}
+
 			// (re)set line counts
 			ADDEDCount = hunk.NewRange.Start
 			REMOVEDCount = hunk.OrigRange.Start
+		case inHunk && isNoNewlineMarker(l):
+			hunk.RawSpan.End = lineEnd(idx)
+			// The marker applies to the line before it.
+			switch lastLineMode {
+			case ADDED:
+				file.NewNoNewlineAtEOF = true
+			case REMOVED:
+				file.OrigNoNewlineAtEOF = true
+			case UNCHANGED:
+				file.NewNoNewlineAtEOF = true
+				file.OrigNoNewlineAtEOF = true
+			}
+		case inHunk && parentCounts != nil && len(l) >= len(parentCounts):
+			line, err := parseCombinedLine(l, len(parentCounts))
+			if err != nil {
+				return nil, newParseError(idx, file, err)
+			}
+			line.Position = diffPosCount
+			setOffset(line, idx)
+			lastLineMode = line.Mode
+			hunk.RawSpan.End = lineEnd(idx)
+
+			// A combined line belongs to the result unless it is removed
+			// from a parent. It belongs to the parents it is removed from
+			// or, if it is in the result, to those it is not added to.
+			var whole *DiffLine
+			if line.Mode != REMOVED {
+				newLine := arena.new(*line)
+				newLine.Number = ADDEDCount
+				hunk.NewRange.Lines = append(hunk.NewRange.Lines, newLine)
+				whole = newLine
+				ADDEDCount++
+			}
+			for i, pm := range line.ParentModes {
+				if pm == ADDED || (pm == UNCHANGED && line.Mode == REMOVED) {
+					continue
+				}
+				parentLine := arena.new(*line)
+				parentLine.Number = parentCounts[i]
+				hunk.ParentRanges[i].Lines = append(hunk.ParentRanges[i].Lines, parentLine)
+				if whole == nil {
+					whole = parentLine
+				}
+				parentCounts[i]++
+			}
+			if whole != nil {
+				hunk.WholeRange.Lines = append(hunk.WholeRange.Lines, whole)
+			}
+			hunk.OrigRange = hunk.ParentRanges[0]
 		case inHunk && isSourceLine(l):
 			m, err := lineMode(l)
 			if err != nil {
-				return nil, err
+				return nil, newParseError(idx, file, err)
 			}
+			// With -U0, a range of length 0 is valid, but has no
+			// lines, e.g. the new range of a hunk removing lines.
+			if !o.recount && ((*m != REMOVED && hunk.NewRange.Length == 0) || (*m != ADDED && hunk.OrigRange.Length == 0)) {
+				return nil, newParseError(idx, file, fmt.Errorf("%w: %s", ErrLineOutsideHunk, l))
+			}
+			lastLineMode = *m
+			hunk.RawSpan.End = lineEnd(idx)
 			line := DiffLine{
 				Mode:     *m,
 				Content:  l[1:],
 				Position: diffPosCount,
 			}
-			newLine := line
-			origLine := line
+			setOffset(&line, idx)
 
 			// add lines to ranges
 			switch *m {
 			case ADDED:
+				newLine := arena.new(line)
 				newLine.Number = ADDEDCount
-				hunk.NewRange.Lines = append(hunk.NewRange.Lines, &newLine)
-				hunk.WholeRange.Lines = append(hunk.WholeRange.Lines, &newLine)
+				hunk.NewRange.Lines = append(hunk.NewRange.Lines, newLine)
+				hunk.WholeRange.Lines = append(hunk.WholeRange.Lines, newLine)
 				ADDEDCount++
 
 			case REMOVED:
+				origLine := arena.new(line)
 				origLine.Number = REMOVEDCount
-				hunk.OrigRange.Lines = append(hunk.OrigRange.Lines, &origLine)
-				hunk.WholeRange.Lines = append(hunk.WholeRange.Lines, &origLine)
+				hunk.OrigRange.Lines = append(hunk.OrigRange.Lines, origLine)
+				hunk.WholeRange.Lines = append(hunk.WholeRange.Lines, origLine)
 				REMOVEDCount++
 
 			case UNCHANGED:
+				newLine := arena.new(line)
 				newLine.Number = ADDEDCount
-				hunk.NewRange.Lines = append(hunk.NewRange.Lines, &newLine)
-				hunk.WholeRange.Lines = append(hunk.WholeRange.Lines, &newLine)
+				hunk.NewRange.Lines = append(hunk.NewRange.Lines, newLine)
+				hunk.WholeRange.Lines = append(hunk.WholeRange.Lines, newLine)
+				origLine := arena.new(line)
 				origLine.Number = REMOVEDCount
-				hunk.OrigRange.Lines = append(hunk.OrigRange.Lines, &origLine)
+				hunk.OrigRange.Lines = append(hunk.OrigRange.Lines, origLine)
 				ADDEDCount++
 				REMOVEDCount++
 			}
 		}
 	}
 
+	for _, f := range diff.Files {
+		for _, h := range f.Hunks {
+			o.counted(h)
+		}
+
+		// New and deleted files have no name on one side, whatever the
+		// "diff" line says.
+		switch f.Mode {
+		case NEW:
+			f.OrigName = ""
+		case DELETED:
+			f.NewName = ""
+		}
+
+		if o.slashPaths {
+			f.OrigName = strings.ReplaceAll(f.OrigName, `\`, "/")
+			f.NewName = strings.ReplaceAll(f.NewName, `\`, "/")
+		}
+
+		// Some tools write a rename as a plain edit with differing names.
+		// Copies have their own headers, so are never MODIFIED here.
+		if f.Mode == MODIFIED && f.OrigName != "" && f.NewName != "" && f.OrigName != f.NewName {
+			f.Mode = RENAMED
+		}
+	}
+
+	diff.Trailing = trailingBlankLines(diffString)
+	if file != nil {
+		file.RawSpan.End = len(diffString) - len(diff.Trailing)
+	}
 	return &diff, nil
 }
 
+// trailingBlankLines returns the blank lines at the end of s, after the
+// newline ending its last line. If s is all blank lines, it is returned.
+func trailingBlankLines(s string) string {
+	trimmed := strings.TrimRight(s, "\n")
+	if trimmed == "" {
+		return s
+	}
+	return s[min(len(trimmed)+1, len(s)):]
+}
+
+// counted sets the Length of each of h's ranges to the number of its lines,
+// if WithRecount is set, and returns h.
+func (o *parseOptions) counted(h *DiffHunk) *DiffHunk {
+	if !o.recount {
+		return h
+	}
+	h.OrigRange.Length = len(h.OrigRange.Lines)
+	h.NewRange.Length = len(h.NewRange.Lines)
+	for i := range h.ParentRanges {
+		h.ParentRanges[i].Length = len(h.ParentRanges[i].Lines)
+	}
+	return h
+}
+
+// isComplete reports whether the hunk has all the lines its header counts, so
+// that a line after them can't belong to it.
+func (h *DiffHunk) isComplete() bool {
+	if len(h.ParentRanges) > 0 {
+		for _, r := range h.ParentRanges {
+			if len(r.Lines) < r.Length {
+				return false
+			}
+		}
+	} else if len(h.OrigRange.Lines) < h.OrigRange.Length {
+		return false
+	}
+	return len(h.NewRange.Lines) >= h.NewRange.Length
+}
+
+// hunkFollows reports whether h starts after prev ends, in both the original
+// and the new file.
+func hunkFollows(prev, h *DiffHunk) bool {
+	// A range covering no lines starts at the line before it.
+	follows := func(prev, next DiffRange) bool {
+		end := prev.Start + max(prev.Length, 1)
+		first := next.Start
+		if next.Length == 0 {
+			first++
+		}
+		return first >= end
+	}
+	return follows(prev.OrigRange, h.OrigRange) && follows(prev.NewRange, h.NewRange)
+}
+
+// splitInto appends the lines of s to lines, as strings.Split(s, "\n")
+// would return them.
+func splitInto(lines []string, s string) []string {
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			return append(lines, s)
+		}
+		lines = append(lines, s[:i])
+		s = s[i+1:]
+	}
+}
+
+// lineStarts appends the offset in the text of each of lines, as splitInto
+// returns them, to starts.
+func lineStarts(starts []int, lines []string) []int {
+	var off int
+	for _, l := range lines {
+		starts = append(starts, off)
+		off += len(l) + 1
+	}
+	return starts
+}
+
+// lineArena hands out DiffLines from a single allocation, falling back to
+// allocating each line once it is used up.
+type lineArena []DiffLine
+
+// new returns a pointer to a copy of l.
+func (a *lineArena) new(l DiffLine) *DiffLine {
+	if len(*a) == cap(*a) {
+		return &l
+	}
+	*a = append(*a, l)
+	return &(*a)[len(*a)-1]
+}
+
+// makeLines returns a slice with room for n lines, or nil if n is 0.
+func makeLines(n int) []*DiffLine {
+	if n <= 0 {
+		return nil
+	}
+	return make([]*DiffLine, 0, n)
+}
+
+// isExtendedHeaderLine reports whether l is one of git's extended header
+// lines, which come between the "diff" line and the "---" line.
+func isExtendedHeaderLine(l string) bool {
+	for _, prefix := range []string{
+		indexPrefix,
+		oldModePrefix,
+		newModePrefix,
+		newFileModePrefix,
+		deletedFileModePrefix,
+		similarityPrefix,
+		"dissimilarity index ",
+		renamePrefix,
+		copyFromPrefix,
+		copyToPrefix,
+	} {
+		if strings.HasPrefix(l, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFileMode parses the octal file mode on a header line starting with
+// prefix.
+func parseFileMode(l, prefix string) (int, error) {
+	mode, err := strconv.ParseInt(strings.TrimPrefix(l, prefix), 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidFileMode, l)
+	}
+	return int(mode), nil
+}
+
+// isFileHeaderLine reports whether l is one of the lines following "diff"
+// that describe a file.
+func isFileHeaderLine(l string) bool {
+	for _, prefix := range []string{
+		"--- ",
+		"+++ ",
+		newFileModePrefix,
+		deletedFileModePrefix,
+		oldModePrefix,
+		newModePrefix,
+		similarityPrefix,
+		renameFromPrefix,
+		renameToPrefix,
+		copyFromPrefix,
+		copyToPrefix,
+	} {
+		if strings.HasPrefix(l, prefix) {
+			return true
+		}
+	}
+	return l == binaryPatch ||
+		(strings.HasPrefix(l, binaryFilesPrefix) && strings.HasSuffix(l, binaryFilesSuffix)) ||
+		(strings.HasPrefix(l, hgBinaryPrefix) && strings.HasSuffix(l, hgBinarySuffix))
+}
+
+// parseCombinedHunkHeader parses the header of a combined diff hunk, e.g.
+// "@@@ -1,3 -1,3 +1,4 @@@", which has one range per parent followed by the
+// range of the result.
+func parseCombinedHunkHeader(l string) (*DiffHunk, error) {
+	invalid := fmt.Errorf("%w: %s", ErrInvalidHunkHeader, l)
+
+	marker := l[:len(l)-len(strings.TrimLeft(l, "@"))]
+	body := strings.TrimPrefix(l, marker+" ")
+	end := strings.Index(body, " "+marker)
+	if end < 0 {
+		return nil, invalid
+	}
+	fields := strings.Fields(body[:end])
+	if len(fields) != len(marker) {
+		return nil, invalid
+	}
+
+	parseRange := func(field string, sign byte) (DiffRange, error) {
+		if field[0] != sign {
+			return DiffRange{}, invalid
+		}
+		parts := strings.SplitN(field[1:], ",", 2)
+		start, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return DiffRange{}, invalid
+		}
+		length := 1
+		if len(parts) == 2 {
+			if length, err = strconv.Atoi(parts[1]); err != nil {
+				return DiffRange{}, invalid
+			}
+		}
+		return DiffRange{Start: start, Length: length}, nil
+	}
+
+	hunk := &DiffHunk{}
+	for _, field := range fields[:len(fields)-1] {
+		r, err := parseRange(field, '-')
+		if err != nil {
+			return nil, err
+		}
+		hunk.ParentRanges = append(hunk.ParentRanges, r)
+	}
+	r, err := parseRange(fields[len(fields)-1], '+')
+	if err != nil {
+		return nil, err
+	}
+	hunk.NewRange = r
+	hunk.OrigRange = hunk.ParentRanges[0]
+	hunk.HunkHeader = strings.TrimPrefix(body[end+len(marker)+1:], " ")
+	return hunk, nil
+}
+
+// parseCombinedLine parses a line of a combined diff hunk, which starts with
+// one mode column per parent.
+func parseCombinedLine(l string, parents int) (*DiffLine, error) {
+	line := &DiffLine{
+		Mode:    UNCHANGED,
+		Content: l[parents:],
+	}
+	for i := 0; i < parents; i++ {
+		m, err := lineMode(l[i:])
+		if err != nil {
+			return nil, err
+		}
+		line.ParentModes = append(line.ParentModes, *m)
+		switch {
+		case *m == REMOVED:
+			line.Mode = REMOVED
+		case *m == ADDED && line.Mode == UNCHANGED:
+			line.Mode = ADDED
+		}
+	}
+	return line, nil
+}
+
+// parseFileName returns the file name from a "---" or "+++" line, with any
+// quoting undone and the "a/" or "b/" prefix removed.
+func parseFileName(s string) string {
+	if !strings.HasPrefix(s, `"`) {
+		// Git ends the name with a tab if it contains a space. Other
+		// tools put a timestamp after the tab.
+		if i := strings.IndexByte(s, '\t'); i >= 0 {
+			s = s[:i]
+		}
+	}
+	s = unquoteFileName(s)
+	if s == devNull {
+		return s
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// parseDiffNames returns the original and new names from a "diff --git",
+// "diff --cc" or Mercurial "diff -r" line, or empty names if it can't tell
+// them apart. Unquoted
+// names containing spaces are ambiguous, so they are only split where both
+// halves name the same file, at the only space, or at the only space before
+// "b/" when the line has the usual "a/" and "b/" prefixes. Prefixes are only
+// removed from the start of a name, so names such as "a/a/a" are kept whole.
+func parseDiffNames(l string) (string, string) {
+	if name, ok := strings.CutPrefix(l, "diff --cc "); ok {
+		name = unquoteFileName(name)
+		return name, name
+	}
+	if name, ok := strings.CutPrefix(l, "diff --combined "); ok {
+		name = unquoteFileName(name)
+		return name, name
+	}
+	if s, ok := strings.CutPrefix(l, "diff -r "); ok {
+		// Mercurial gives the one or two revisions compared, e.g.
+		// "diff -r 9117c6561b0b -r 273ce12ad8f1 dir/f.txt", then the
+		// name, unquoted and without prefixes.
+		_, s, _ = strings.Cut(s, " ")
+		if rest, ok := strings.CutPrefix(s, "-r "); ok {
+			_, s, _ = strings.Cut(rest, " ")
+		}
+		return s, s
+	}
+	s, ok := strings.CutPrefix(l, "diff --git ")
+	if !ok {
+		return "", ""
+	}
+	strip := func(name string) string {
+		if strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/") {
+			return name[2:]
+		}
+		return name
+	}
+
+	// A quoted first name ends at its closing quote.
+	if strings.HasPrefix(s, `"`) {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil || !strings.HasPrefix(s[len(q):], " ") {
+			return "", ""
+		}
+		return strip(unquoteFileName(q)), strip(unquoteFileName(s[len(q)+1:]))
+	}
+	// An unquoted first name may be followed by a quoted second.
+	if i := strings.Index(s, ` "`); i >= 0 {
+		return strip(s[:i]), strip(unquoteFileName(s[i+1:]))
+	}
+
+	var spaces []int
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' {
+			spaces = append(spaces, i)
+		}
+	}
+	for _, i := range spaces {
+		if a, b := strip(s[:i]), strip(s[i+1:]); a == b {
+			return a, b
+		}
+	}
+	if len(spaces) == 1 {
+		return strip(s[:spaces[0]]), strip(s[spaces[0]+1:])
+	}
+
+	// The names differ, as for a rename. Split at the only space followed
+	// by "b/", if the first name starts with "a/".
+	if !strings.HasPrefix(s, "a/") {
+		return "", ""
+	}
+	split := -1
+	for _, i := range spaces {
+		if strings.HasPrefix(s[i+1:], "b/") {
+			if split >= 0 {
+				return "", ""
+			}
+			split = i
+		}
+	}
+	if split < 0 {
+		return "", ""
+	}
+	return s[2:split], s[split+3:]
+}
+
+// parseBinaryNames returns the original and new names from the middle of a
+// "Binary files ... differ" line, "<orig> and <new>", and false if it can't
+// tell them apart. Names may be quoted, and unquoted names may contain
+// spaces and even " and ", so where there is more than one " and " the line
+// is split where the halves are origName and newName, the names known from
+// the "diff" line, or failing that where the second half is quoted, where
+// both halves name the same file, or where they have the "a/" and "b/"
+// prefixes.
+func parseBinaryNames(s, origName, newName string) (string, string, bool) {
+	const sep = " and "
+	if strings.HasPrefix(s, `"`) {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", false
+		}
+		rest, ok := strings.CutPrefix(s[len(q):], sep)
+		if !ok {
+			return "", "", false
+		}
+		return parseFileName(q), parseFileName(rest), true
+	}
+
+	var splits []int
+	for i := 0; ; {
+		j := strings.Index(s[i:], sep)
+		if j < 0 {
+			break
+		}
+		splits = append(splits, i+j)
+		i += j + 1
+	}
+	prefixed := func(name, prefix string) bool {
+		return name == devNull || strings.HasPrefix(name, prefix)
+	}
+	for _, match := range []func(a, b string) bool{
+		func(a, b string) bool { return true },
+		func(a, b string) bool {
+			a, b = parseFileName(a), parseFileName(b)
+			return (a == origName || a == devNull) && (b == newName || b == devNull)
+		},
+		func(a, b string) bool { _, err := strconv.Unquote(b); return err == nil },
+		func(a, b string) bool { return parseFileName(a) == parseFileName(b) },
+		func(a, b string) bool {
+			return prefixed(a, "a/") && prefixed(b, "b/")
+		},
+	} {
+		split := -1
+		for _, i := range splits {
+			if match(s[:i], s[i+len(sep):]) {
+				if split >= 0 {
+					split = -2
+					break
+				}
+				split = i
+			}
+		}
+		if split >= 0 {
+			return parseFileName(s[:split]), parseFileName(s[split+len(sep):]), true
+		}
+	}
+	return "", "", false
+}
+
+// unquoteFileName undoes git's quoting of file names that contain special
+// characters, e.g. "caf\303\251". Unquoted names are returned as they
+// are.
+func unquoteFileName(s string) string {
+	if !strings.HasPrefix(s, `"`) {
+		return s
+	}
+	if i := strings.LastIndex(s, `"`); i > 0 {
+		s = s[:i+1]
+	}
+	if name, err := strconv.Unquote(s); err == nil {
+		return name
+	}
+	return s
+}
+
+var renameSimilarityReg = regexp.MustCompile(` \(\d+%\)$`)
+
+// parseRenamePath expands a rename written as "old => new", where the
+// changed part of the path may be wrapped in braces with a common prefix and
+// suffix, e.g. "src/{a => b}/x.go".
+func parseRenamePath(s string) (string, string, bool) {
+	s = renameSimilarityReg.ReplaceAllString(s, "")
+
+	open := strings.Index(s, "{")
+	close := strings.LastIndex(s, "}")
+	if open < 0 || close < open {
+		parts := strings.SplitN(s, " => ", 2)
+		if len(parts) != 2 {
+			return "", "", false
+		}
+		return parts[0], parts[1], true
+	}
+
+	parts := strings.SplitN(s[open+1:close], " => ", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	prefix, suffix := s[:open], s[close+1:]
+	join := func(middle string) string {
+		// An empty side such as "src/{ => b}/x.go" must not leave a
+		// doubled slash behind.
+		if middle == "" && strings.HasPrefix(suffix, "/") && (prefix == "" || strings.HasSuffix(prefix, "/")) {
+			return prefix + suffix[1:]
+		}
+		return prefix + middle + suffix
+	}
+	return join(parts[0]), join(parts[1]), true
+}
+
+// isNoNewlineMarker reports whether line is git's "\ No newline at end of
+// file" marker. Git localizes the message, so any line starting with a
+// backslash and a space is treated as the marker.
+func isNoNewlineMarker(line string) bool {
+	return strings.HasPrefix(line, `\ `)
+}
+
 func isSourceLine(line string) bool {
-	if line == `\ No newline at end of file` {
+	if isNoNewlineMarker(line) {
 		return false
 	}
 	if l := len(line); l == 0 || (l >= 3 && (line[:3] == "---" || line[:3] == "+++")) {
@@ -291,7 +1396,403 @@ func isSourceLine(line string) bool {
 	return true
 }
 
+// IsNew reports whether the file was created by the diff.
+func (f *DiffFile) IsNew() bool {
+	return f.Mode == NEW
+}
+
+// IsDeleted reports whether the file was deleted by the diff.
+func (f *DiffFile) IsDeleted() bool {
+	return f.Mode == DELETED
+}
+
+// IsModified reports whether the file was changed in place, i.e. neither
+// created, deleted nor renamed.
+func (f *DiffFile) IsModified() bool {
+	return f.Mode == MODIFIED
+}
+
+// IsRenamed reports whether the file was renamed, with or without changes
+// to its content.
+func (f *DiffFile) IsRenamed() bool {
+	return f.Mode == RENAMED
+}
+
+// IsCopied reports whether the file is a copy of another, with or without
+// changes to its content. The file it was copied from is left as it is.
+func (f *DiffFile) IsCopied() bool {
+	return f.Mode == COPIED
+}
+
+// IsRenameWithChanges reports whether the file was renamed and its content
+// changed too, as git shows with a similarity index under 100% and hunks,
+// or a binary diff, for the edits. A change of mode alone doesn't count.
+func (f *DiffFile) IsRenameWithChanges() bool {
+	return f.Mode == RENAMED && (len(f.Hunks) > 0 || f.IsBinary)
+}
+
+// IsModeChangeOnly reports whether the diff only changes the file's mode,
+// e.g. making it executable, as git shows with "old mode" and "new mode"
+// lines and no hunks. A renamed file is more than a mode change.
+func (f *DiffFile) IsModeChangeOnly() bool {
+	return f.Mode == MODIFIED && len(f.Hunks) == 0 && !f.IsBinary &&
+		f.OldMode != 0 && f.NewMode != 0 && f.OldMode != f.NewMode
+}
+
+// IsEmpty reports whether the file is an empty file created or deleted by
+// the diff, which git shows with its mode and no hunks.
+func (f *DiffFile) IsEmpty() bool {
+	return (f.Mode == NEW || f.Mode == DELETED) && len(f.Hunks) == 0 && !f.IsBinary
+}
+
+// OrigEndsWithNewline reports whether the original file ends with a newline.
+func (f *DiffFile) OrigEndsWithNewline() bool {
+	return !f.OrigNoNewlineAtEOF
+}
+
+// NewEndsWithNewline reports whether the new file ends with a newline.
+func (f *DiffFile) NewEndsWithNewline() bool {
+	return !f.NewNoNewlineAtEOF
+}
+
+// Additions returns the number of lines added to the file. Binary files have
+// no lines, so report 0; see IsBinary.
+func (f *DiffFile) Additions() int {
+	return f.countLines(ADDED)
+}
+
+// Deletions returns the number of lines removed from the file. Binary files
+// have no lines, so report 0; see IsBinary.
+func (f *DiffFile) Deletions() int {
+	return f.countLines(REMOVED)
+}
+
+// ChangeRatio returns the share of the lines shown in the file's hunks that
+// are added or removed, from 0 to 1, as a measure of how much of what the
+// diff shows of the file changed. Unchanged lines count once. A new or deleted
+// file is 1, and a file with no lines, such as a binary file or a pure
+// rename, is 0. Only the lines in the hunks are known, so a one-line edit of
+// a long file still has a high ratio with little context.
+func (f *DiffFile) ChangeRatio() float64 {
+	var changed, total int
+	for _, h := range f.Hunks {
+		for _, l := range h.WholeRange.Lines {
+			if l.Mode != UNCHANGED {
+				changed++
+			}
+			total++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(changed) / float64(total)
+}
+
+// AddedLines returns the lines added to the file, in diff order. Their
+// numbers are lines of the new file, which for a renamed file is NewName.
+func (f *DiffFile) AddedLines() []*DiffLine {
+	return f.AppendLines(make([]*DiffLine, 0, f.Additions()), ADDED)
+}
+
+// RemovedLines returns the lines removed from the file, in diff order. Their
+// numbers are lines of the original file, which for a renamed file is
+// OrigName.
+func (f *DiffFile) RemovedLines() []*DiffLine {
+	return f.AppendLines(make([]*DiffLine, 0, f.Deletions()), REMOVED)
+}
+
+// AppendLines appends the file's lines of the given mode to dst, in diff
+// order, and returns the extended slice. Unchanged lines are appended as
+// the copy in NewRange. Passing the result of an earlier call, truncated to
+// dst[:0], avoids allocating a slice each time.
+func (f *DiffFile) AppendLines(dst []*DiffLine, mode DiffLineMode) []*DiffLine {
+	for _, h := range f.Hunks {
+		for _, l := range h.WholeRange.Lines {
+			if l.Mode == mode {
+				dst = append(dst, l)
+			}
+		}
+	}
+	return dst
+}
+
+// AppendLineNumbers is like AppendLines, but appends the lines' numbers.
+func (f *DiffFile) AppendLineNumbers(dst []int, mode DiffLineMode) []int {
+	for _, h := range f.Hunks {
+		for _, l := range h.WholeRange.Lines {
+			if l.Mode == mode {
+				dst = append(dst, l.Number)
+			}
+		}
+	}
+	return dst
+}
+
+func (f *DiffFile) countLines(mode DiffLineMode) int {
+	var n int
+	for _, h := range f.Hunks {
+		for _, l := range h.WholeRange.Lines {
+			if l.Mode == mode {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// GitHubPosition returns the position of the line as expected by GitHub's
+// review comments API: the number of lines down from the first "@@" hunk
+// header of the file, so the line just below it is 1. Later hunk headers
+// count as a line, and the count starts again with each file.
+//
+// Position already holds this value: Parse counts it as it reads the diff,
+// 1-based from the first hunk header, not 0-based and not from the top of the
+// file's header. GitHubPosition exists so that callers needn't know that.
+// Position is only set by Parse, so once hunks or lines are filtered, moved or
+// added it is stale; DiffFile.GitHubPosition counts the position afresh from
+// the file's hunks instead.
+func (l *DiffLine) GitHubPosition() int {
+	return l.Position
+}
+
+// GitHubPosition returns the position of l among the file's lines as GitHub's
+// review comments API expects it, see DiffLine.GitHubPosition. Rather than
+// reading l.Position, it counts down the file's hunks as String prints them,
+// so it is right for a diff changed since it was parsed, or built by hand. It
+// returns false if l isn't one of the lines of the file's hunks.
+func (f *DiffFile) GitHubPosition(l *DiffLine) (int, bool) {
+	var pos int
+	for i, h := range f.Hunks {
+		if i > 0 {
+			// The hunk's header.
+			pos++
+		}
+		var origNoNewline, newNoNewline bool
+		if i == len(f.Hunks)-1 {
+			origNoNewline, newNoNewline = f.OrigNoNewlineAtEOF, f.NewNoNewlineAtEOF
+		}
+		lastOrig, lastNew := h.lastLines()
+		// The original side of an unchanged line is a copy of its own,
+		// in OrigRange only.
+		origLines := h.OrigRange.Lines
+		if len(h.ParentRanges) > 0 {
+			origLines = nil
+		}
+		var orig int
+		for j, line := range h.WholeRange.Lines {
+			pos++
+			if line == l {
+				return pos, true
+			}
+			if line.Mode != ADDED && orig < len(origLines) {
+				if origLines[orig] == l {
+					return pos, true
+				}
+				orig++
+			}
+			if (origNoNewline && j == lastOrig) || (newNoNewline && j == lastNew) {
+				// The "\ No newline at end of file" marker.
+				pos++
+			}
+		}
+	}
+	return 0, false
+}
+
+// PositionFor returns the GitHub position, see GitHubPosition, of the given
+// line of the file. With side REMOVED, line is a number in the original
+// file, like a review comment on GitHub's "LEFT" side; with ADDED or
+// UNCHANGED it is a number in the new file, the "RIGHT" side. It returns
+// false if the diff doesn't show the line.
+func (f *DiffFile) PositionFor(line int, side DiffLineMode) (int, bool) {
+	var l *DiffLine
+	var ok bool
+	if side == REMOVED {
+		_, l, ok = f.HunkForOrigLine(line)
+	} else {
+		_, l, ok = f.HunkForNewLine(line)
+	}
+	if !ok {
+		return 0, false
+	}
+	return l.Position, true
+}
+
+// LineForPosition returns the line of the file at the given GitHub position,
+// see GitHubPosition. Unchanged lines are numbered as in the new file. It
+// returns false if pos is out of range or falls on a hunk header or
+// "\ No newline at end of file" marker.
+func (f *DiffFile) LineForPosition(pos int) (*DiffLine, bool) {
+	for _, h := range f.Hunks {
+		lines := h.WholeRange.Lines
+		if len(lines) == 0 || lines[len(lines)-1].Position < pos {
+			continue
+		}
+		i := sort.Search(len(lines), func(i int) bool {
+			return lines[i].Position >= pos
+		})
+		if lines[i].Position == pos {
+			return lines[i], true
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// HunkForNewLine returns the hunk covering line n of the new file and the
+// line itself, which is either added or unchanged. It returns false if the
+// line falls outside every hunk, i.e. the diff doesn't show it. The hunks
+// are expected in file order, as git writes them, and are found with a binary
+// search over their ranges.
+func (f *DiffFile) HunkForNewLine(n int) (*DiffHunk, *DiffLine, bool) {
+	return f.hunkForLine(n, func(h *DiffHunk) *DiffRange { return &h.NewRange })
+}
+
+// HunkForOrigLine returns the hunk covering line n of the original file and
+// the line itself, which is either removed or unchanged. It returns false if
+// the line falls outside every hunk.
+func (f *DiffFile) HunkForOrigLine(n int) (*DiffHunk, *DiffLine, bool) {
+	return f.hunkForLine(n, func(h *DiffHunk) *DiffRange { return &h.OrigRange })
+}
+
+// HunksInRange returns the hunks whose new range overlaps lines start to
+// end of the new file, inclusive. A hunk that only removes lines covers no
+// new lines; it is returned if start to end includes the line on either side
+// of the removal.
+func (f *DiffFile) HunksInRange(start, end int) []*DiffHunk {
+	var hunks []*DiffHunk
+	for _, h := range f.Hunks {
+		first, last := h.NewRange.Start, h.NewRange.Start+h.NewRange.Length-1
+		if h.NewRange.Length == 0 {
+			last = first + 1
+		}
+		if first <= end && last >= start {
+			hunks = append(hunks, h)
+		}
+	}
+	return hunks
+}
+
+func (f *DiffFile) hunkForLine(n int, side func(*DiffHunk) *DiffRange) (*DiffHunk, *DiffLine, bool) {
+	// Find the last hunk starting at or before n. A zero-length range
+	// covers no lines, and its start is the line before it.
+	i := sort.Search(len(f.Hunks), func(i int) bool {
+		r := side(f.Hunks[i])
+		return r.Start > n || (r.Length == 0 && r.Start == n)
+	}) - 1
+	if i < 0 {
+		return nil, nil, false
+	}
+	h := f.Hunks[i]
+	r := side(h)
+	if n >= r.Start+r.Length {
+		return nil, nil, false
+	}
+	if j := n - r.Start; j < len(r.Lines) && r.Lines[j].Number == n {
+		return h, r.Lines[j], true
+	}
+	// The header disagrees with the lines, so look for the line by number.
+	for _, l := range r.Lines {
+		if l.Number == n {
+			return h, l, true
+		}
+	}
+	return nil, nil, false
+}
+
+// NewFileContent returns the content of a file created by the diff, which is
+// made up entirely of added lines. It returns false if the file is not new.
+func (f *DiffFile) NewFileContent() (string, bool) {
+	if f.Mode != NEW {
+		return "", false
+	}
+	var lines []string
+	for _, h := range f.Hunks {
+		for _, l := range h.NewRange.Lines {
+			lines = append(lines, l.Content)
+		}
+	}
+	return joinLines(lines, f.NewNoNewlineAtEOF), true
+}
+
+// OrigFileContent returns the content of a file deleted by the diff, which
+// is made up entirely of removed lines. It returns false if the file is not
+// deleted.
+func (f *DiffFile) OrigFileContent() (string, bool) {
+	if f.Mode != DELETED {
+		return "", false
+	}
+	var lines []string
+	for _, h := range f.Hunks {
+		for _, l := range h.OrigRange.Lines {
+			lines = append(lines, l.Content)
+		}
+	}
+	return joinLines(lines, f.OrigNoNewlineAtEOF), true
+}
+
+// joinLines joins lines into file content, ending it with a newline unless
+// noNewlineAtEOF is set.
+func joinLines(lines []string, noNewlineAtEOF bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	content := strings.Join(lines, "\n")
+	if !noNewlineAtEOF {
+		content += "\n"
+	}
+	return content
+}
+
 // Length returns the hunks line length
 func (hunk *DiffHunk) Length() int {
 	return len(hunk.WholeRange.Lines) + 1
 }
+
+// Added returns the lines the hunk adds, in order.
+func (hunk *DiffHunk) Added() []*DiffLine {
+	return hunk.linesWithMode(ADDED)
+}
+
+// Removed returns the lines the hunk removes, in order.
+func (hunk *DiffHunk) Removed() []*DiffLine {
+	return hunk.linesWithMode(REMOVED)
+}
+
+// Context returns the unchanged lines of the hunk, in order. OrigRange and
+// NewRange each hold a copy of an unchanged line; Context returns each line
+// once, as the copy in NewRange, so Number is its line in the new file. Pairs
+// gives the copy in OrigRange too, numbered in the original file.
+func (hunk *DiffHunk) Context() []*DiffLine {
+	return hunk.linesWithMode(UNCHANGED)
+}
+
+func (hunk *DiffHunk) linesWithMode(mode DiffLineMode) []*DiffLine {
+	var lines []*DiffLine
+	for _, l := range hunk.WholeRange.Lines {
+		if l.Mode == mode {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// ContextCount returns the number of unchanged lines in the hunk, which are
+// shown around the changes for context.
+func (hunk *DiffHunk) ContextCount() int {
+	var n int
+	for _, l := range hunk.WholeRange.Lines {
+		if l.Mode == UNCHANGED {
+			n++
+		}
+	}
+	return n
+}
+
+// ContainsLine reports whether line n of the range's side of the file is
+// in the range, that is whether Start <= n <= Start+Length-1. An empty range,
+// as on the original side of a hunk that only adds lines, contains no lines.
+func (r *DiffRange) ContainsLine(n int) bool {
+	return r.Length > 0 && r.Start <= n && n < r.Start+r.Length
+}
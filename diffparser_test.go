@@ -4,8 +4,15 @@
 package diffparser
 
 import (
+	"errors"
 	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"unsafe"
 
 	"github.com/stretchr/testify/require"
 )
@@ -14,21 +21,20 @@ import (
 // chars), and diffed files that are not in the current directory.
 
 func setup(t *testing.T) *Diff {
-	byt, err := ioutil.ReadFile("example.diff")
-	require.NoError(t, err)
-
-	diff, err := Parse(string(byt))
+	diff, err := ParseFile("example.diff")
 	require.NoError(t, err)
 	require.Equal(t, len(diff.Files), 6)
+	require.NoError(t, diff.Validate())
 
 	return diff
 }
 func TestFileModeAndNaming(t *testing.T) {
 	diff := setup(t)
 	for i, expected := range []struct {
-		mode     FileMode
-		origName string
-		newName  string
+		mode             FileMode
+		origName         string
+		newName          string
+		oldMode, newMode int
 	}{
 		{
 			mode:     MODIFIED,
@@ -39,26 +45,31 @@ func TestFileModeAndNaming(t *testing.T) {
 			mode:     DELETED,
 			origName: "file2",
 			newName:  "",
+			oldMode:  0100644,
 		},
 		{
 			mode:     DELETED,
 			origName: "file3",
 			newName:  "",
+			oldMode:  0100644,
 		},
 		{
 			mode:     NEW,
 			origName: "",
 			newName:  "file4",
+			newMode:  0100644,
 		},
 		{
 			mode:     NEW,
 			origName: "",
 			newName:  "newname",
+			newMode:  0100644,
 		},
 		{
 			mode:     DELETED,
 			origName: "symlink",
 			newName:  "",
+			oldMode:  0120000,
 		},
 	} {
 		file := diff.Files[i]
@@ -66,7 +77,312 @@ func TestFileModeAndNaming(t *testing.T) {
 		require.Equal(t, expected.mode, file.Mode)
 		require.Equal(t, expected.origName, file.OrigName)
 		require.Equal(t, expected.newName, file.NewName)
+		require.Equal(t, expected.oldMode, file.OldMode)
+		require.Equal(t, expected.newMode, file.NewMode)
+	}
+}
+
+func TestNewAndDeletedFileModes(t *testing.T) {
+	input := `diff --git a/run.sh b/run.sh
+deleted file mode 100755
+index 1111111..0000000
+--- a/run.sh
++++ /dev/null
+@@ -1 +0,0 @@
+-echo hi
+diff --git a/link b/link
+new file mode 120000
+index 0000000..2222222
+--- /dev/null
++++ b/link
+@@ -0,0 +1 @@
++target
+\ No newline at end of file
+`
+	diff, err := Parse(input)
+	require.NoError(t, err)
+
+	deleted := diff.Files[0]
+	require.Equal(t, DELETED, deleted.Mode)
+	require.Equal(t, 0100755, deleted.OldMode)
+	require.Equal(t, 0, deleted.NewMode)
+
+	created := diff.Files[1]
+	require.Equal(t, NEW, created.Mode)
+	require.Equal(t, 0, created.OldMode)
+	require.Equal(t, 0120000, created.NewMode)
+
+	// The modes are written back in the headers.
+	require.Equal(t, input, diff.String())
+}
+
+func TestFileModePredicates(t *testing.T) {
+	diff := setup(t)
+	for i, expected := range []struct {
+		isNew, isDeleted, isModified bool
+	}{
+		{isModified: true},
+		{isDeleted: true},
+		{isDeleted: true},
+		{isNew: true},
+		{isNew: true},
+		{isDeleted: true},
+	} {
+		file := diff.Files[i]
+		require.Equal(t, expected.isNew, file.IsNew(), i)
+		require.Equal(t, expected.isDeleted, file.IsDeleted(), i)
+		require.Equal(t, expected.isModified, file.IsModified(), i)
+		require.False(t, file.IsRenamed(), i)
+	}
+
+	renamed := &DiffFile{Mode: RENAMED}
+	require.True(t, renamed.IsRenamed())
+	require.False(t, renamed.IsModified())
+}
+
+func TestRenameWithChanges(t *testing.T) {
+	diff, err := Parse(`diff --git a/old.go b/new.go
+similarity index 88%
+rename from old.go
+rename to new.go
+index 1111111..2222222 100644
+--- a/old.go
++++ b/new.go
+@@ -1,3 +1,3 @@
+ package a
+-var x = 1
++var x = 2
+ func f() {}
+@@ -10 +10,2 @@
+ end
++more
+diff --git a/a.txt b/b.txt
+similarity index 100%
+rename from a.txt
+rename to b.txt
+diff --git a/c.png b/d.png
+similarity index 90%
+rename from c.png
+rename to d.png
+index 3333333..4444444 100644
+Binary files a/c.png and b/d.png differ
+`)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 3)
+
+	edited := diff.Files[0]
+	require.Equal(t, RENAMED, edited.Mode)
+	require.Equal(t, "old.go", edited.OrigName)
+	require.Equal(t, "new.go", edited.NewName)
+	require.Equal(t, 88, edited.SimilarityIndex)
+	require.Len(t, edited.Hunks, 2)
+	require.Equal(t, "var x = 1", edited.Hunks[0].Removed()[0].Content)
+	require.Equal(t, "var x = 2", edited.Hunks[0].Added()[0].Content)
+	require.Equal(t, map[string][]int{"new.go": {2, 11}, "d.png": {}}, diff.Changed())
+	require.Equal(t, map[string][]int{"old.go": {2}, "c.png": {}}, diff.Removed())
+	require.True(t, edited.IsRenamed())
+	require.True(t, edited.IsRenameWithChanges())
+
+	require.True(t, diff.Files[1].IsRenamed())
+	require.False(t, diff.Files[1].IsRenameWithChanges())
+	require.Equal(t, 100, diff.Files[1].SimilarityIndex)
+	require.Empty(t, diff.Files[1].Hunks)
+	require.True(t, diff.Files[2].IsRenameWithChanges())
+
+	for _, f := range setup(t).Files {
+		require.False(t, f.IsRenameWithChanges())
+	}
+	chmod := &DiffFile{Mode: RENAMED, OldMode: 0100644, NewMode: 0100755}
+	require.False(t, chmod.IsRenameWithChanges())
+}
+
+func TestIsModeChangeOnly(t *testing.T) {
+	diff, err := Parse(`diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git a/edit.sh b/edit.sh
+old mode 100644
+new mode 100755
+index 1111111..2222222
+--- a/edit.sh
++++ b/edit.sh
+@@ -1 +1 @@
+-a
++b
+diff --git a/old.sh b/new.sh
+old mode 100644
+new mode 100755
+similarity index 100%
+rename from old.sh
+rename to new.sh
+`)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 3)
+
+	chmod := diff.Files[0]
+	require.Equal(t, MODIFIED, chmod.Mode)
+	require.Equal(t, 0100644, chmod.OldMode)
+	require.Equal(t, 0100755, chmod.NewMode)
+	require.Empty(t, chmod.Hunks)
+	require.True(t, chmod.IsModeChangeOnly())
+	require.False(t, chmod.IsEmpty())
+
+	require.False(t, diff.Files[1].IsModeChangeOnly())
+	require.False(t, diff.Files[2].IsModeChangeOnly())
+	for _, f := range setup(t).Files {
+		require.False(t, f.IsModeChangeOnly())
+	}
+	require.False(t, (&DiffFile{Mode: MODIFIED, OldMode: 0100644, NewMode: 0100644}).IsModeChangeOnly())
+	require.False(t, (&DiffFile{Mode: MODIFIED, OldMode: 0100644, NewMode: 0100755, IsBinary: true}).IsModeChangeOnly())
+}
+
+func TestEmptyFiles(t *testing.T) {
+	input := `diff --git a/empty b/empty
+new file mode 100644
+index 0000000..e69de29
+diff --git a/gone b/gone
+deleted file mode 100644
+index e69de29..0000000
+`
+	diff, err := Parse(input)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 2)
+
+	created := diff.Files[0]
+	require.Equal(t, NEW, created.Mode)
+	require.Equal(t, "", created.OrigName)
+	require.Equal(t, "empty", created.NewName)
+	require.Equal(t, 0100644, created.NewMode)
+	require.Empty(t, created.Hunks)
+	require.True(t, created.IsEmpty())
+
+	deleted := diff.Files[1]
+	require.Equal(t, DELETED, deleted.Mode)
+	require.Equal(t, "gone", deleted.OrigName)
+	require.Equal(t, "", deleted.NewName)
+	require.Empty(t, deleted.Hunks)
+	require.True(t, deleted.IsEmpty())
+
+	require.Equal(t, input, diff.String())
+
+	for _, f := range setup(t).Files {
+		require.False(t, f.IsEmpty())
+	}
+}
+
+func TestNamesFromDiffLine(t *testing.T) {
+	for line, expected := range map[string][2]string{
+		"diff --git a/file b/file":                   {"file", "file"},
+		"diff --git a/old b/new":                     {"old", "new"},
+		"diff --git a/with space b/with space":       {"with space", "with space"},
+		"diff --git a/a b/c b/a b/c":                 {"a b/c", "a b/c"},
+		`diff --git "a/caf\303\251" "b/caf\303\251"`: {"café", "café"},
+		`diff --git a/x "b/tab\there"`:               {"x", "tab\there"},
+		"diff --cc merged.go":                        {"merged.go", "merged.go"},
+		"diff --git a/one two b/three four":          {"one two", "three four"},
+		"diff --git a/x b/y b/z":                     {"", ""},
+		"diff --git one two three four":              {"", ""},
+		"diff --git a/a/a b/a/a":                     {"a/a", "a/a"},
+		"diff --git a/b/b/b b/b/b/b":                 {"b/b/b", "b/b/b"},
+		"diff --git a/src/b/foo b/src/b/foo":         {"src/b/foo", "src/b/foo"},
+		"diff --git a/a/a b/b/b":                     {"a/a", "b/b"},
+		"diff --git a/a b/c b/b b/c":                 {"", ""},
+		"diff --git a/x a/y b/b/z w":                 {"x a/y", "b/z w"},
+		"diff -u old/file new/file":                  {"", ""},
+		"diff -r 9117c6561b0b -r 273ce12ad8f1 f.txt": {"f.txt", "f.txt"},
+		"diff -r 9117c6561b0b dir/with space":        {"dir/with space", "dir/with space"},
+		"diff -r 9117c6561b0b":                       {"", ""},
+	} {
+		orig, new := parseDiffNames(line)
+		require.Equal(t, expected, [2]string{orig, new}, line)
+	}
+
+	diff, err := Parse("diff --git a/b/b/b b/b/b/b\nnew file mode 100644\n" +
+		"diff --git a/a/a b/b/b\nsimilarity index 100%\nrename from a/a\nrename to b/b\n")
+	require.NoError(t, err)
+	require.Equal(t, NEW, diff.Files[0].Mode)
+	require.Equal(t, "b/b/b", diff.Files[0].NewName)
+	require.Equal(t, RENAMED, diff.Files[1].Mode)
+	require.Equal(t, "a/a", diff.Files[1].OrigName)
+	require.Equal(t, "b/b", diff.Files[1].NewName)
+	require.Equal(t, "diff --git a/b/b/b b/b/b/b\nnew file mode 100644\n", diff.Files[0].String())
+}
+
+func TestCommand(t *testing.T) {
+	diff := setup(t)
+	for _, f := range diff.Files {
+		require.Equal(t, strings.SplitN(f.DiffHeader, "\n", 2)[0], f.Command)
 	}
+	require.Equal(t, "diff --git a/file1 b/file1", diff.Files[0].Command)
+
+	diff, err := Parse("diff --git \"a/caf\\303\\251\" \"b/caf\\303\\251\"\nold mode 100644\nnew mode 100755\n" +
+		"diff --cc merged.go\nindex 1111111,2222222..3333333\n")
+	require.NoError(t, err)
+	require.Equal(t, `diff --git "a/caf\303\251" "b/caf\303\251"`, diff.Files[0].Command)
+	require.Equal(t, "diff --cc merged.go", diff.Files[1].Command)
+}
+
+func TestWindowsPaths(t *testing.T) {
+	for _, test := range []struct {
+		diff, name string
+	}{{
+		diff: `diff --git a/C:/Users/me/file.txt b/C:/Users/me/file.txt
+--- a/C:/Users/me/file.txt
++++ b/C:/Users/me/file.txt
+`,
+		name: "C:/Users/me/file.txt",
+	}, {
+		diff: `diff --git a/C:\Users\me\file.txt b/C:\Users\me\file.txt
+--- a/C:\Users\me\file.txt
++++ b/C:\Users\me\file.txt
+`,
+		name: `C:\Users\me\file.txt`,
+	}, {
+		diff: `diff --git "a/C:\\Users\\me\\caf\303\251.txt" "b/C:\\Users\\me\\caf\303\251.txt"
+--- "a/C:\\Users\\me\\caf\303\251.txt"
++++ "b/C:\\Users\\me\\caf\303\251.txt"
+`,
+		name: `C:\Users\me\café.txt`,
+	}} {
+		diff, err := Parse(test.diff + "@@ -1 +1 @@\n-a\n+b\n")
+		require.NoError(t, err)
+		f := diff.Files[0]
+		require.Equal(t, test.name, f.OrigName)
+		require.Equal(t, test.name, f.NewName)
+
+		// Names with backslashes are written quoted, as git does.
+		reparsed, err := Parse(diff.String())
+		require.NoError(t, err)
+		requireEquivalent(t, diff, reparsed)
+	}
+}
+
+func TestExtendedHeaders(t *testing.T) {
+	diff, err := Parse(`diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..7898192
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++a
+`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"new file mode 100644", "index 0000000..7898192"}, diff.Files[0].ExtendedHeaders)
+
+	// Headers that aren't interpreted are written out as they are.
+	input := `diff --git a/big.txt b/big.txt
+dissimilarity index 85%
+index 3333333..4444444 100644
+--- a/big.txt
++++ b/big.txt
+@@ -1 +1 @@
+-x
++y
+`
+	diff, err = Parse(input)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dissimilarity index 85%", "index 3333333..4444444 100644"}, diff.Files[0].ExtendedHeaders)
+	require.Equal(t, input, diff.String())
 }
 
 func TestHunk(t *testing.T) {
@@ -135,3 +451,1596 @@ func TestHunk(t *testing.T) {
 		require.Equal(t, line, *newRange.Lines[i])
 	}
 }
+
+func TestLocalizedNoNewlineMarker(t *testing.T) {
+	diff, err := Parse(`diff --git a/file b/file
+index 257cc56..3bd1f0e 100644
+--- a/file
++++ b/file
+@@ -1 +1 @@
+-foo
+\ Kein Zeilenumbruch am Dateiende
++bar
+\ Kein Zeilenumbruch am Dateiende
+`)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 1)
+
+	hunk := diff.Files[0].Hunks[0]
+	require.Len(t, hunk.WholeRange.Lines, 2)
+	require.Equal(t, "foo", hunk.OrigRange.Lines[0].Content)
+	require.Equal(t, "bar", hunk.NewRange.Lines[0].Content)
+}
+
+func TestNoTrailingNewline(t *testing.T) {
+	header := "diff --git a/file b/file\n--- a/file\n+++ b/file\n@@ -1,2 +1,2 @@\n"
+	for _, test := range []struct {
+		about                    string
+		input                    string
+		origNoNewline, noNewline bool
+	}{{
+		about: "ends in an added line",
+		input: header + " a\n-b\n+c",
+	}, {
+		about:     "ends in the no-newline marker",
+		input:     header + " a\n-b\n+c\n\\ No newline at end of file",
+		noNewline: true,
+	}, {
+		about:         "ends in both markers",
+		input:         header + " a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file",
+		origNoNewline: true,
+		noNewline:     true,
+	}} {
+		t.Run(test.about, func(t *testing.T) {
+			diff, err := Parse(test.input)
+			require.NoError(t, err)
+			require.Len(t, diff.Files, 1)
+			file := diff.Files[0]
+			require.Equal(t, test.origNoNewline, file.OrigNoNewlineAtEOF)
+			require.Equal(t, test.noNewline, file.NewNoNewlineAtEOF)
+
+			lines := file.Hunks[0].WholeRange.Lines
+			require.Len(t, lines, 3)
+			last := lines[2]
+			require.Equal(t, ADDED, last.Mode)
+			require.Equal(t, "c", last.Content)
+			require.Equal(t, 2, last.Number)
+			require.Equal(t, "b", lines[1].Content)
+			require.Equal(t, 2, lines[1].Number)
+
+			// Adding the final newline doesn't change the result.
+			withNewline, err := Parse(test.input + "\n")
+			require.NoError(t, err)
+			requireEquivalent(t, withNewline, diff)
+		})
+	}
+}
+
+func TestEndsWithNewline(t *testing.T) {
+	diff := setup(t)
+	for i, expected := range []struct {
+		orig bool
+		new  bool
+	}{
+		{orig: true, new: true},
+		{orig: true, new: true},
+		{orig: false, new: true},
+		{orig: true, new: false},
+		{orig: true, new: true},
+		{orig: false, new: true},
+	} {
+		file := diff.Files[i]
+		require.Equal(t, expected.orig, file.OrigEndsWithNewline(), file.OrigName)
+		require.Equal(t, expected.new, file.NewEndsWithNewline(), file.NewName)
+	}
+
+	for _, test := range []struct {
+		diff string
+		orig bool
+		new  bool
+	}{
+		{
+			diff: `diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -1 +1 @@
+-foo
+\ No newline at end of file
++foo
+`,
+			orig: false,
+			new:  true,
+		}, {
+			diff: `diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -1,2 +1,2 @@
+-foo
++bar
+ baz
+\ No newline at end of file
+`,
+			orig: false,
+			new:  false,
+		},
+	} {
+		diff, err := Parse(test.diff)
+		require.NoError(t, err)
+		file := diff.Files[0]
+		require.Equal(t, test.orig, file.OrigEndsWithNewline())
+		require.Equal(t, test.new, file.NewEndsWithNewline())
+	}
+}
+
+func TestRename(t *testing.T) {
+	for _, test := range []struct {
+		line     string
+		origName string
+		newName  string
+	}{
+		{
+			line:     "rename from old/file.go\nrename to new/file.go",
+			origName: "old/file.go",
+			newName:  "new/file.go",
+		}, {
+			line:     "rename old.go => new.go (100%)",
+			origName: "old.go",
+			newName:  "new.go",
+		}, {
+			line:     "rename src/{a => b}/x.go (90%)",
+			origName: "src/a/x.go",
+			newName:  "src/b/x.go",
+		}, {
+			line:     "rename src/{old.go => new.go} (100%)",
+			origName: "src/old.go",
+			newName:  "src/new.go",
+		}, {
+			line:     "rename {a => b}/x.go (100%)",
+			origName: "a/x.go",
+			newName:  "b/x.go",
+		}, {
+			line:     "rename src/{ => sub}/x.go (100%)",
+			origName: "src/x.go",
+			newName:  "src/sub/x.go",
+		}, {
+			line:     "rename {sub => }/x.go (100%)",
+			origName: "sub/x.go",
+			newName:  "x.go",
+		},
+	} {
+		diff, err := Parse("diff --git a/x b/x\nsimilarity index 90%\n" + test.line + "\n")
+		require.NoError(t, err)
+		file := diff.Files[0]
+		require.Equal(t, RENAMED, file.Mode, test.line)
+		require.Equal(t, 90, file.SimilarityIndex)
+		require.Equal(t, test.origName, file.OrigName, test.line)
+		require.Equal(t, test.newName, file.NewName, test.line)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	input := `diff --git a/a.go b/b.go
+similarity index 75%
+copy from a.go
+copy to b.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/b.go
+@@ -1 +1 @@
+-package a
++package b
+`
+	diff, err := Parse(input)
+	require.NoError(t, err)
+	f := diff.Files[0]
+	require.Equal(t, COPIED, f.Mode)
+	require.True(t, f.IsCopied())
+	require.False(t, f.IsRenamed())
+	require.Equal(t, "a.go", f.OrigName)
+	require.Equal(t, "b.go", f.NewName)
+	require.Equal(t, input, diff.String())
+	require.Equal(t, 1, diff.Totals().CopiedFiles)
+	require.Equal(t, 0, diff.Totals().RenamedFiles)
+
+	files, err := diff.Apply(map[string]string{"a.go": "package a\n"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a.go": "package a\n", "b.go": "package b\n"}, files)
+}
+
+func TestCopySimilarityIndex(t *testing.T) {
+	diff, err := Parse(`diff --git a/a.go b/b.go
+similarity index 75%
+copy from a.go
+copy to b.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/b.go
+@@ -1 +1 @@
+-package a
++package b
+diff --git a/c.go b/d.go
+similarity index 100%
+copy from c.go
+copy to d.go
+`)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 2)
+	for _, f := range diff.Files {
+		require.Equal(t, COPIED, f.Mode)
+	}
+	require.Equal(t, 75, diff.Files[0].SimilarityIndex)
+	require.Equal(t, "a.go", diff.Files[0].OrigName)
+	require.Equal(t, "b.go", diff.Files[0].NewName)
+	require.Len(t, diff.Files[0].Hunks, 1)
+	require.Equal(t, 100, diff.Files[1].SimilarityIndex)
+	require.Empty(t, diff.Files[1].Hunks)
+
+	// Printed, the copies have their similarity and copy headers alone.
+	require.Equal(t, diff.Raw, diff.String())
+	require.NotContains(t, diff.String(), "rename")
+
+	// Applied, the files copied from are kept.
+	files, err := diff.Apply(map[string]string{"a.go": "package a\n", "c.go": "package c\n"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"a.go": "package a\n",
+		"b.go": "package b\n",
+		"c.go": "package c\n",
+		"d.go": "package c\n",
+	}, files)
+}
+
+func TestRenameWithoutHeaders(t *testing.T) {
+	diff, err := Parse(`diff --git a/old.txt b/new.txt
+index 1111111..2222222 100644
+--- a/old.txt
++++ b/new.txt
+@@ -1 +1 @@
+-a
++b
+diff --git a/same.txt b/same.txt
+index 3333333..4444444 100644
+--- a/same.txt
++++ b/same.txt
+@@ -1 +1 @@
+-a
++b
+`)
+	require.NoError(t, err)
+	require.Equal(t, RENAMED, diff.Files[0].Mode)
+	require.Equal(t, "old.txt", diff.Files[0].OrigName)
+	require.Equal(t, "new.txt", diff.Files[0].NewName)
+	require.Equal(t, 0, diff.Files[0].SimilarityIndex)
+	require.Equal(t, MODIFIED, diff.Files[1].Mode)
+}
+
+func TestCombinedDiff(t *testing.T) {
+	diff, err := Parse(`diff --cc f.txt
+index 0e42946,9982675..b4a7def
+--- a/f.txt
++++ b/f.txt
+@@@ -1,3 -1,3 +1,4 @@@
+  line1
+- from main
+ -from b
+++merged
+  line3
+++extra
+`)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 1)
+
+	file := diff.Files[0]
+	require.Equal(t, MODIFIED, file.Mode)
+	require.Equal(t, "f.txt", file.OrigName)
+	require.Equal(t, "f.txt", file.NewName)
+	require.Len(t, file.Hunks, 1)
+
+	hunk := file.Hunks[0]
+	require.Len(t, hunk.ParentRanges, 2)
+	require.Equal(t, 1, hunk.ParentRanges[0].Start)
+	require.Equal(t, 3, hunk.ParentRanges[1].Length)
+	require.Equal(t, 1, hunk.NewRange.Start)
+	require.Equal(t, 4, hunk.NewRange.Length)
+
+	var whole []string
+	for _, l := range hunk.WholeRange.Lines {
+		whole = append(whole, l.Content)
+	}
+	require.Equal(t, []string{"line1", "from main", "from b", "merged", "line3", "extra"}, whole)
+	require.Equal(t, []DiffLineMode{REMOVED, UNCHANGED}, hunk.WholeRange.Lines[1].ParentModes)
+	require.Equal(t, ADDED, hunk.WholeRange.Lines[3].Mode)
+
+	var parents [2][]int
+	for i, r := range hunk.ParentRanges {
+		for _, l := range r.Lines {
+			parents[i] = append(parents[i], l.Number)
+		}
+	}
+	require.Equal(t, []int{1, 2, 3}, parents[0])
+	require.Equal(t, []int{1, 2, 3}, parents[1])
+	require.Equal(t, hunk.ParentRanges[0], hunk.OrigRange)
+
+	var result []string
+	for _, l := range hunk.NewRange.Lines {
+		result = append(result, l.Content)
+	}
+	require.Equal(t, []string{"line1", "merged", "line3", "extra"}, result)
+	require.Equal(t, 4, hunk.NewRange.Lines[3].Number)
+}
+
+func TestNetLines(t *testing.T) {
+	diff := setup(t)
+	// file1 +1 -1, file2 -4, file3 -4, file4 +1, newname +4, symlink -1.
+	require.Equal(t, -4, diff.NetLines())
+}
+
+func TestChanged(t *testing.T) {
+	diff := setup(t)
+	require.Equal(t, map[string][]int{
+		"file1":   {1},
+		"file4":   {1},
+		"newname": {1, 2, 3, 4},
+	}, diff.Changed())
+}
+
+func TestRemoved(t *testing.T) {
+	diff := setup(t)
+	require.Equal(t, map[string][]int{
+		"file1":   {3},
+		"file2":   {1, 2, 3, 4},
+		"file3":   {1, 2, 3, 4},
+		"symlink": {1},
+	}, diff.Removed())
+}
+
+func TestChangedAndRemovedRename(t *testing.T) {
+	diff, err := Parse(`diff --git a/old.txt b/new.txt
+similarity index 80%
+rename from old.txt
+rename to new.txt
+index 1111111..2222222 100644
+--- a/old.txt
++++ b/new.txt
+@@ -5,2 +5,2 @@
+-five
++5
+ six
+@@ -1,2 +1,2 @@
+-one
++1
+ two
+`)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]int{"new.txt": {1, 5}}, diff.Changed())
+	require.Equal(t, map[string][]int{"old.txt": {1, 5}}, diff.Removed())
+}
+
+func TestTotals(t *testing.T) {
+	diff := setup(t)
+	require.Equal(t, 6, diff.TotalFiles())
+	require.Equal(t, 6, diff.TotalHunks())
+
+	require.Equal(t, 0, (&Diff{}).TotalHunks())
+
+	diff, err := Parse("diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n@@ -9 +9 @@\n-x\n+y\n")
+	require.NoError(t, err)
+	require.Equal(t, 5, diff.TotalLines())
+	require.Equal(t, 0, (&Diff{}).TotalLines())
+}
+
+func TestNewFileContent(t *testing.T) {
+	diff := setup(t)
+
+	content, ok := diff.Files[3].NewFileContent()
+	require.True(t, ok)
+	require.Equal(t, "added new file", content)
+
+	content, ok = diff.Files[4].NewFileContent()
+	require.True(t, ok)
+	require.Equal(t, "other\nlines\nin\nfile2\n", content)
+
+	_, ok = diff.Files[0].NewFileContent()
+	require.False(t, ok)
+}
+
+func TestOrigFileContent(t *testing.T) {
+	diff := setup(t)
+
+	content, ok := diff.Files[1].OrigFileContent()
+	require.True(t, ok)
+	require.Equal(t, "other\nlines\nin\nfile2\n", content)
+
+	content, ok = diff.Files[2].OrigFileContent()
+	require.True(t, ok)
+	require.Equal(t, "still\nmore\nin\nfile3", content)
+
+	_, ok = diff.Files[0].OrigFileContent()
+	require.False(t, ok)
+}
+
+func TestHunkHeaderSpacing(t *testing.T) {
+	for _, header := range []string{
+		"@@ -1,4 +1,4 @@ section",
+		"@@  -1,4  +1,4  @@ section",
+		"@@ -1,4 +1,4@@ section",
+		"@@ -1,4 +1,4 @@section",
+	} {
+		diff, err := Parse("diff --git a/file b/file\n--- a/file\n+++ b/file\n" + header + "\n-a\n+b\n")
+		require.NoError(t, err, header)
+		hunk := diff.Files[0].Hunks[0]
+		require.Equal(t, DiffRange{Start: 1, Length: 4, Lines: hunk.OrigRange.Lines}, hunk.OrigRange, header)
+		require.Equal(t, DiffRange{Start: 1, Length: 4, Lines: hunk.NewRange.Lines}, hunk.NewRange, header)
+		require.Equal(t, "section", hunk.HunkHeader, header)
+	}
+
+	diff, err := Parse("diff --git a/file b/file\n--- a/file\n+++ b/file\n@@ -1 +1 @@\n-a\n+b\n")
+	require.NoError(t, err)
+	require.Equal(t, "", diff.Files[0].Hunks[0].HunkHeader)
+}
+
+func TestHunkHeaderOmittedLength(t *testing.T) {
+	// As "diff -U0" writes a hunk changing only line 6.
+	diff, err := Parse("diff --git a/file b/file\n--- a/file\n+++ b/file\n@@ -6 +6 @@\n-a\n+b\n")
+	require.NoError(t, err)
+	hunk := diff.Files[0].Hunks[0]
+	require.Equal(t, 6, hunk.OrigRange.Start)
+	require.Equal(t, 1, hunk.OrigRange.Length)
+	require.Equal(t, 6, hunk.NewRange.Start)
+	require.Equal(t, 1, hunk.NewRange.Length)
+}
+
+func TestHunkForLine(t *testing.T) {
+	diff, err := Parse(`diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -2,3 +2,4 @@
+ a
++b
+ c
+ d
+@@ -10,2 +11,1 @@
+ e
+-f
+@@ -20,0 +21,2 @@
++g
++h
+`)
+	require.NoError(t, err)
+	file := diff.Files[0]
+	first, second, third := file.Hunks[0], file.Hunks[1], file.Hunks[2]
+
+	for n, expected := range map[int]struct {
+		hunk    *DiffHunk
+		content string
+	}{
+		1:  {},
+		2:  {first, "a"},
+		3:  {first, "b"},
+		5:  {first, "d"},
+		6:  {},
+		11: {second, "e"},
+		12: {},
+		20: {},
+		21: {third, "g"},
+		22: {third, "h"},
+		23: {},
+	} {
+		hunk, line, ok := file.HunkForNewLine(n)
+		require.Equal(t, expected.hunk != nil, ok, n)
+		require.Equal(t, expected.hunk, hunk, n)
+		if ok {
+			require.Equal(t, n, line.Number, n)
+			require.Equal(t, expected.content, line.Content, n)
+			require.NotEqual(t, REMOVED, line.Mode, n)
+		} else {
+			require.Nil(t, line, n)
+		}
+	}
+	for n, expected := range map[int]struct {
+		hunk    *DiffHunk
+		content string
+	}{
+		1:  {},
+		4:  {first, "d"},
+		5:  {},
+		10: {second, "e"},
+		11: {second, "f"},
+		12: {},
+		20: {},
+		21: {},
+	} {
+		hunk, line, ok := file.HunkForOrigLine(n)
+		require.Equal(t, expected.hunk != nil, ok, n)
+		require.Equal(t, expected.hunk, hunk, n)
+		if ok {
+			require.Equal(t, n, line.Number, n)
+			require.Equal(t, expected.content, line.Content, n)
+			require.NotEqual(t, ADDED, line.Mode, n)
+		} else {
+			require.Nil(t, line, n)
+		}
+	}
+}
+
+func TestHunkForLineExample(t *testing.T) {
+	diff := setup(t)
+	file1, file2, file4 := diff.Files[0], diff.Files[1], diff.Files[3]
+
+	hunk, line, ok := file1.HunkForNewLine(1)
+	require.True(t, ok)
+	require.Equal(t, file1.Hunks[0], hunk)
+	require.Equal(t, "add a line", line.Content)
+	_, _, ok = file1.HunkForNewLine(4)
+	require.True(t, ok)
+	_, _, ok = file1.HunkForNewLine(5)
+	require.False(t, ok)
+
+	hunk, line, ok = file1.HunkForOrigLine(3)
+	require.True(t, ok)
+	require.Equal(t, file1.Hunks[0], hunk)
+	require.Equal(t, "in", line.Content)
+	require.Equal(t, REMOVED, line.Mode)
+
+	// A deleted file has no new lines, and a new file no original ones.
+	_, _, ok = file2.HunkForNewLine(1)
+	require.False(t, ok)
+	_, _, ok = file2.HunkForOrigLine(4)
+	require.True(t, ok)
+	_, _, ok = file4.HunkForOrigLine(1)
+	require.False(t, ok)
+	hunk, _, ok = file4.HunkForNewLine(1)
+	require.True(t, ok)
+	require.Equal(t, file4.Hunks[0], hunk)
+}
+
+func TestHunkAddedRemovedContext(t *testing.T) {
+	diff, err := Parse(`diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -3,4 +3,5 @@
+ a
+-b
++B
++B2
+ c
+-d
++D
+`)
+	require.NoError(t, err)
+	hunk := diff.Files[0].Hunks[0]
+	contents := func(lines []*DiffLine) []string {
+		var s []string
+		for _, l := range lines {
+			s = append(s, l.Content)
+		}
+		return s
+	}
+
+	require.Equal(t, []string{"B", "B2", "D"}, contents(hunk.Added()))
+	require.Equal(t, []int{4, 5, 7}, lineNumbers(hunk.Added()))
+	require.Equal(t, []string{"b", "d"}, contents(hunk.Removed()))
+	require.Equal(t, []int{4, 6}, lineNumbers(hunk.Removed()))
+
+	// Each unchanged line is returned once, numbered in the new file, and
+	// is the line NewRange holds.
+	context := hunk.Context()
+	require.Equal(t, []string{"a", "c"}, contents(context))
+	require.Equal(t, []int{3, 6}, lineNumbers(context))
+	require.Len(t, context, hunk.ContextCount())
+	require.True(t, context[1] == hunk.NewRange.Lines[3])
+	for _, p := range hunk.Pairs() {
+		if p.New == context[1] {
+			require.Equal(t, 5, p.Orig.Number)
+		}
+	}
+
+	empty := &DiffHunk{}
+	require.Empty(t, empty.Added())
+	require.Empty(t, empty.Removed())
+	require.Empty(t, empty.Context())
+}
+
+func TestContextCount(t *testing.T) {
+	diff := setup(t)
+	require.Equal(t, 3, diff.Files[0].Hunks[0].ContextCount())
+	require.Equal(t, 0, diff.Files[1].Hunks[0].ContextCount())
+	require.Equal(t, 0, (&DiffHunk{}).ContextCount())
+}
+
+func TestUnifiedZero(t *testing.T) {
+	// Produced by "git diff -U0": a hunk that only inserts lines after
+	// line 5 and one that only removes lines 9 and 10.
+	diff, err := Parse(`diff --git a/f b/f
+index 1111111..2222222 100644
+--- a/f
++++ b/f
+@@ -5,0 +6,2 @@
++new1
++new2
+@@ -9,2 +10,0 @@
+-old9
+-old10
+`)
+	require.NoError(t, err)
+	require.NoError(t, diff.Validate())
+	file := diff.Files[0]
+	insert, remove := file.Hunks[0], file.Hunks[1]
+
+	require.Equal(t, DiffRange{Start: 5, Length: 0}, insert.OrigRange)
+	require.Equal(t, 6, insert.NewRange.Start)
+	require.Equal(t, 2, insert.NewRange.Length)
+	require.Equal(t, []int{6, 7}, lineNumbers(insert.NewRange.Lines))
+	require.Equal(t, DiffRange{Start: 10, Length: 0}, remove.NewRange)
+	require.Equal(t, 9, remove.OrigRange.Start)
+	require.Equal(t, 2, remove.OrigRange.Length)
+	require.Equal(t, []int{9, 10}, lineNumbers(remove.OrigRange.Lines))
+
+	require.Equal(t, 2, file.Additions())
+	require.Equal(t, 2, file.Deletions())
+	require.Equal(t, 0, insert.ContextCount())
+	require.Equal(t, map[string][]int{"f": {6, 7}}, diff.Changed())
+	require.Equal(t, map[string][]int{"f": {9, 10}}, diff.Removed())
+
+	// Empty ranges contain no lines.
+	_, _, ok := file.HunkForOrigLine(5)
+	require.False(t, ok)
+	_, _, ok = file.HunkForNewLine(10)
+	require.False(t, ok)
+	h, l, ok := file.HunkForOrigLine(10)
+	require.True(t, ok)
+	require.Equal(t, remove, h)
+	require.Equal(t, "old10", l.Content)
+
+	// The hunks apply where their headers say.
+	var orig []string
+	for i := 1; i <= 12; i++ {
+		orig = append(orig, "old"+strconv.Itoa(i))
+	}
+	result, err := file.Apply(strings.Join(orig, "\n") + "\n")
+	require.NoError(t, err)
+	require.Equal(t, "old1\nold2\nold3\nold4\nold5\nnew1\nnew2\nold6\nold7\nold8\nold11\nold12\n", result)
+
+	require.Equal(t, diff.Raw, diff.String())
+}
+
+func lineNumbers(lines []*DiffLine) []int {
+	var numbers []int
+	for _, l := range lines {
+		numbers = append(numbers, l.Number)
+	}
+	return numbers
+}
+
+func TestLineMode(t *testing.T) {
+	for line, expected := range map[string]DiffLineMode{
+		" a": UNCHANGED,
+		"+":  ADDED,
+		"-b": REMOVED,
+	} {
+		m, err := lineMode(line)
+		require.NoError(t, err)
+		require.Equal(t, expected, *m, line)
+	}
+	for _, line := range []string{"", "*", "\\"} {
+		_, err := lineMode(line)
+		require.True(t, errors.Is(err, ErrInvalidLine), line)
+	}
+	_, err := lineMode("")
+	require.EqualError(t, err, "invalid line: empty line")
+}
+
+func TestContainsLine(t *testing.T) {
+	diff, err := Parse("diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -10,3 +10,0 @@\n-a\n-b\n-c\n")
+	require.NoError(t, err)
+	h := diff.Files[0].Hunks[0]
+	for n, expected := range map[int]bool{9: false, 10: true, 12: true, 13: false} {
+		require.Equal(t, expected, h.OrigRange.ContainsLine(n), n)
+	}
+	for _, n := range []int{9, 10, 11} {
+		require.False(t, h.NewRange.ContainsLine(n), n)
+	}
+
+	r := DiffRange{Start: 1, Length: 1}
+	require.False(t, r.ContainsLine(0))
+	require.True(t, r.ContainsLine(1))
+	require.False(t, r.ContainsLine(2))
+}
+
+func TestHunksInRange(t *testing.T) {
+	diff, err := Parse(`diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -2,3 +2,4 @@
+ a
++b
+ c
+ d
+@@ -10,2 +11,0 @@
+-e
+-f
+@@ -20,2 +19,3 @@
+ g
++h
+ i
+`)
+	require.NoError(t, err)
+	file := diff.Files[0]
+	first, second, third := file.Hunks[0], file.Hunks[1], file.Hunks[2]
+
+	for _, test := range []struct {
+		start, end int
+		expected   []*DiffHunk
+	}{
+		{1, 1, nil},
+		{1, 2, []*DiffHunk{first}},
+		{5, 5, []*DiffHunk{first}},
+		{6, 10, nil},
+		{6, 11, []*DiffHunk{second}},
+		{12, 18, []*DiffHunk{second}},
+		{13, 18, nil},
+		{21, 30, []*DiffHunk{third}},
+		{22, 30, nil},
+		{1, 100, []*DiffHunk{first, second, third}},
+	} {
+		require.Equal(t, test.expected, file.HunksInRange(test.start, test.end), "%d-%d", test.start, test.end)
+	}
+}
+
+func TestSort(t *testing.T) {
+	diff := setup(t)
+	file := diff.Files[0]
+	file.Hunks = append(file.Hunks, &DiffHunk{NewRange: DiffRange{Start: 10}}, &DiffHunk{NewRange: DiffRange{Start: 5}})
+
+	rand.New(rand.NewSource(1)).Shuffle(len(diff.Files), func(i, j int) {
+		diff.Files[i], diff.Files[j] = diff.Files[j], diff.Files[i]
+	})
+	file.Hunks[0], file.Hunks[2] = file.Hunks[2], file.Hunks[0]
+	diff.Sort()
+
+	var names []string
+	for _, f := range diff.Files {
+		names = append(names, f.OrigName+"|"+f.NewName)
+	}
+	// Deleted files have no NewName, so they sort first by OrigName.
+	require.Equal(t, []string{"file2|", "file3|", "symlink|", "file1|file1", "|file4", "|newname"}, names)
+
+	var starts []int
+	for _, h := range file.Hunks {
+		starts = append(starts, h.NewRange.Start)
+	}
+	require.Equal(t, []int{1, 5, 10}, starts)
+}
+
+func TestWholeRangeOrder(t *testing.T) {
+	diff, err := Parse(`diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -1,3 +1,3 @@
+-a
++b
+ c
+-d
++e
+`)
+	require.NoError(t, err)
+
+	expected := []DiffLine{
+		{Mode: REMOVED, Number: 1, Content: "a", Position: 1},
+		{Mode: ADDED, Number: 1, Content: "b", Position: 2},
+		{Mode: UNCHANGED, Number: 2, Content: "c", Position: 3},
+		{Mode: REMOVED, Number: 3, Content: "d", Position: 4},
+		{Mode: ADDED, Number: 3, Content: "e", Position: 5},
+	}
+	hunk := diff.Files[0].Hunks[0]
+	require.Len(t, hunk.WholeRange.Lines, len(expected))
+	for i, line := range expected {
+		require.Equal(t, line, *hunk.WholeRange.Lines[i])
+	}
+}
+
+func TestHunkHeaderSectionWithMarkers(t *testing.T) {
+	for header, section := range map[string]string{
+		"@@ -1,3 +1,3 @@ func foo() // @@ marker @@": "func foo() // @@ marker @@",
+		"@@ -1,3 +1,3 @@ @@ -5,6 +7,8 @@":            "@@ -5,6 +7,8 @@",
+		"@@ -1,3 +1,3 @@ @@":                         "@@",
+		"@@ -1,3 +1,3 @@@@ x @@":                     "@@ x @@",
+		"@@ -1,3 +1,3 @@  two spaces @@ ":            " two spaces @@ ",
+		"@@ -1,3 +1,3 @@ a@@b":                       "a@@b",
+	} {
+		diff, err := Parse("diff --git a/f b/f\n--- a/f\n+++ b/f\n" + header + "\n a\n-b\n+c\n d\n")
+		require.NoError(t, err, header)
+		h := diff.Files[0].Hunks[0]
+		require.Equal(t, section, h.HunkHeader, header)
+		require.Equal(t, 1, h.OrigRange.Start, header)
+		require.Len(t, h.WholeRange.Lines, 4, header)
+
+		// The section is written back as it was read.
+		reparsed, err := Parse(diff.String())
+		require.NoError(t, err, header)
+		require.Equal(t, section, reparsed.Files[0].Hunks[0].HunkHeader, header)
+	}
+}
+
+func TestFunctionContext(t *testing.T) {
+	// Produced by "git diff -W".
+	diff, err := Parse(`diff --git a/main.go b/main.go
+index 6ce8b7d..71369ae 100644
+--- a/main.go
++++ b/main.go
+@@ -4,15 +4,15 @@ import "fmt"
+ 
+ // @@ -1,2 +1,2 @@ looks like a header
+ func header() string {
+-	return "@@ -1 +1 @@"
++	return "@@ -1,2 +1,2 @@"
+ }
+ 
+ func first() {
+ 	fmt.Println("one")
+ 	fmt.Println("two")
+ 	fmt.Println("three")
+-	fmt.Println("four")
++	fmt.Println("FOUR")
+ 	fmt.Println("five")
+ 	fmt.Println("six")
+ 	fmt.Println("seven")
+ }
+@@ -21,11 +21,11 @@ func second(s string) { // @@ marker @@ & <special> "chars"
+ 	fmt.Println(s)
+ 	fmt.Println("a")
+ 	fmt.Println("b")
+ 	fmt.Println("c")
+ 	fmt.Println("d")
+ 	fmt.Println("e")
+ 	fmt.Println("f")
+ }
+ var third = []string{
+-	"x",
++	"x", "y",
+ }
+`)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 1)
+
+	hunks := diff.Files[0].Hunks
+	require.Len(t, hunks, 2)
+	require.Equal(t, `import "fmt"`, hunks[0].HunkHeader)
+	require.Equal(t, `func second(s string) { // @@ marker @@ & <special> "chars"`, hunks[1].HunkHeader)
+
+	for i, expected := range []struct {
+		origStart, origLength, newStart, newLength int
+		lines                                      int
+	}{
+		{4, 15, 4, 15, 17},
+		{21, 11, 21, 11, 12},
+	} {
+		h := hunks[i]
+		require.Equal(t, expected.origStart, h.OrigRange.Start)
+		require.Equal(t, expected.origLength, h.OrigRange.Length)
+		require.Len(t, h.OrigRange.Lines, expected.origLength)
+		require.Equal(t, expected.newStart, h.NewRange.Start)
+		require.Equal(t, expected.newLength, h.NewRange.Length)
+		require.Len(t, h.NewRange.Lines, expected.newLength)
+		require.Len(t, h.WholeRange.Lines, expected.lines)
+	}
+
+	// The blank context line and the lines that look like hunk headers are
+	// kept as content.
+	require.Equal(t, DiffLine{Mode: UNCHANGED, Number: 4, Content: "", Position: 1}, *hunks[0].NewRange.Lines[0])
+	require.Equal(t, DiffLine{Mode: UNCHANGED, Number: 5, Content: "// @@ -1,2 +1,2 @@ looks like a header", Position: 2}, *hunks[0].NewRange.Lines[1])
+	require.Equal(t, DiffLine{Mode: ADDED, Number: 7, Content: "\treturn \"@@ -1,2 +1,2 @@\"", Position: 5}, *hunks[0].NewRange.Lines[3])
+	require.Equal(t, DiffLine{Mode: REMOVED, Number: 30, Content: "\t\"x\",", Position: 28}, *hunks[1].OrigRange.Lines[9])
+	require.Equal(t, DiffLine{Mode: ADDED, Number: 30, Content: "\t\"x\", \"y\",", Position: 29}, *hunks[1].NewRange.Lines[9])
+
+	reparsed, err := Parse(diff.String())
+	require.NoError(t, err)
+	requireEquivalent(t, diff, reparsed)
+}
+
+func TestGitHubPosition(t *testing.T) {
+	// Positions as GitHub documents them: 1 for the line below the first
+	// "@@", counting on through later hunk headers and restarting with
+	// each file.
+	diff, err := Parse(`diff --git a/a.txt b/a.txt
+index 0a1b2c3..4d5e6f7 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+@@ -10,2 +10,3 @@ section
+ ten
++ten and a half
+ eleven
+diff --git a/b.txt b/b.txt
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1 @@
+-b
++B
+`)
+	require.NoError(t, err)
+
+	var positions [][]int
+	for _, f := range diff.Files {
+		var p []int
+		for l := range f.Lines() {
+			p = append(p, l.GitHubPosition())
+		}
+		positions = append(positions, p)
+	}
+	require.Equal(t, [][]int{{1, 2, 3, 4, 6, 7, 8}, {1, 2}}, positions)
+
+	// Counted from the hunks, the positions are the same, on either side of
+	// an unchanged line.
+	for _, f := range diff.Files {
+		for _, h := range f.Hunks {
+			for _, lines := range [][]*DiffLine{h.OrigRange.Lines, h.NewRange.Lines} {
+				for _, l := range lines {
+					pos, ok := f.GitHubPosition(l)
+					require.True(t, ok, l.Content)
+					require.Equal(t, l.Position, pos, l.Content)
+				}
+			}
+		}
+	}
+	_, ok := diff.Files[1].GitHubPosition(diff.Files[0].Hunks[0].WholeRange.Lines[0])
+	require.False(t, ok)
+
+	// Once the first hunk is dropped, Position is stale but the file's
+	// count starts again below the remaining hunk's header.
+	file := diff.Files[0]
+	file.Hunks = file.Hunks[1:]
+	line := file.Hunks[0].NewRange.Lines[1]
+	require.Equal(t, "ten and a half", line.Content)
+	require.Equal(t, 7, line.GitHubPosition())
+	pos, ok := file.GitHubPosition(line)
+	require.True(t, ok)
+	require.Equal(t, 2, pos)
+}
+
+func TestFileGitHubPositionNoNewline(t *testing.T) {
+	diff, err := Parse(`diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+ one
+-two
+\ No newline at end of file
++TWO
+\ No newline at end of file
+`)
+	require.NoError(t, err)
+	file := diff.Files[0]
+	// The markers take a position each, as on GitHub.
+	for _, l := range file.Hunks[0].WholeRange.Lines {
+		pos, ok := file.GitHubPosition(l)
+		require.True(t, ok)
+		require.Equal(t, l.Position, pos, l.Content)
+	}
+	pos, _ := file.GitHubPosition(file.Hunks[0].NewRange.Lines[1])
+	require.Equal(t, 4, pos)
+
+	// Lines of a file built by hand have no Position, but are counted.
+	added := &DiffLine{Mode: ADDED, Content: "b"}
+	file = &DiffFile{Hunks: []*DiffHunk{
+		{WholeRange: DiffRange{Lines: []*DiffLine{{Mode: REMOVED, Content: "a"}}}},
+		{WholeRange: DiffRange{Lines: []*DiffLine{added}}},
+	}}
+	pos, ok := file.GitHubPosition(added)
+	require.True(t, ok)
+	require.Equal(t, 3, pos)
+	require.Equal(t, 0, added.GitHubPosition())
+}
+
+func TestPositionFor(t *testing.T) {
+	diff, err := Parse(`diff --git a/a.txt b/a.txt
+index 0a1b2c3..4d5e6f7 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+@@ -10,3 +10,3 @@ section
+ ten
+ eleven
+-twelve
+\ No newline at end of file
++twelve
+`)
+	require.NoError(t, err)
+	file := diff.Files[0]
+
+	for _, test := range []struct {
+		line     int
+		side     DiffLineMode
+		position int
+	}{
+		{line: 1, side: ADDED, position: 1},
+		{line: 1, side: REMOVED, position: 1},
+		{line: 2, side: REMOVED, position: 2},
+		{line: 2, side: ADDED, position: 3},
+		{line: 2, side: UNCHANGED, position: 3},
+		{line: 3, side: REMOVED, position: 4},
+		{line: 10, side: ADDED, position: 6},
+		{line: 12, side: REMOVED, position: 8},
+		{line: 12, side: ADDED, position: 10},
+		{line: 5, side: ADDED},
+		{line: 13, side: REMOVED},
+	} {
+		pos, ok := file.PositionFor(test.line, test.side)
+		require.Equal(t, test.position != 0, ok, "%d %v", test.line, test.side)
+		require.Equal(t, test.position, pos, "%d %v", test.line, test.side)
+	}
+
+	for pos, expected := range map[int]string{
+		1:  "one",
+		2:  "two",
+		3:  "TWO",
+		4:  "three",
+		6:  "ten",
+		8:  "twelve",
+		10: "twelve",
+	} {
+		l, ok := file.LineForPosition(pos)
+		require.True(t, ok, pos)
+		require.Equal(t, expected, l.Content, pos)
+		require.Equal(t, pos, l.Position, pos)
+	}
+	l, _ := file.LineForPosition(8)
+	require.Equal(t, REMOVED, l.Mode)
+
+	// The second hunk header, the no-newline marker and positions out of
+	// range have no line.
+	for _, pos := range []int{0, 5, 9, 11, -1} {
+		_, ok := file.LineForPosition(pos)
+		require.False(t, ok, pos)
+	}
+}
+
+func TestFileAddedAndRemovedLines(t *testing.T) {
+	diff, err := Parse(`diff --git a/old.txt b/new.txt
+similarity index 80%
+rename from old.txt
+rename to new.txt
+index 1111111..2222222 100644
+--- a/old.txt
++++ b/new.txt
+@@ -2,2 +2,2 @@
+-b
++B
+ c
+@@ -7,0 +8,2 @@
++h1
++h2
+@@ -20,2 +21,0 @@
+-t
+-u
+`)
+	require.NoError(t, err)
+	f := diff.Files[0]
+
+	added := f.AddedLines()
+	require.Equal(t, []int{2, 8, 9}, lineNumbers(added))
+	require.Equal(t, "h1", added[1].Content)
+	removed := f.RemovedLines()
+	require.Equal(t, []int{2, 20, 21}, lineNumbers(removed))
+	require.Equal(t, "u", removed[2].Content)
+	require.Equal(t, []int{3}, f.AppendLineNumbers(nil, UNCHANGED))
+	require.Equal(t, []int{0, 2, 8, 9}, f.AppendLineNumbers([]int{0}, ADDED))
+	require.True(t, f.AppendLines(nil, UNCHANGED)[0] == f.Hunks[0].NewRange.Lines[1])
+
+	// They agree with Changed and Removed, which key them by the new and
+	// original names.
+	require.Equal(t, diff.Changed()["new.txt"], f.AppendLineNumbers(nil, ADDED))
+	require.Equal(t, diff.Removed()["old.txt"], f.AppendLineNumbers(nil, REMOVED))
+
+	// Reusing a slice avoids allocating.
+	numbers := f.AppendLineNumbers(nil, ADDED)
+	lines := f.AddedLines()
+	require.Zero(t, testing.AllocsPerRun(10, func() {
+		numbers = f.AppendLineNumbers(numbers[:0], ADDED)
+		lines = f.AppendLines(lines[:0], REMOVED)
+	}))
+
+	empty := &DiffFile{Mode: NEW, NewName: "empty"}
+	require.Empty(t, empty.AddedLines())
+	require.Empty(t, empty.RemovedLines())
+}
+
+func TestChangeRatio(t *testing.T) {
+	diff := setup(t)
+	for i, expected := range []float64{0.4, 1, 1, 1, 1, 1} {
+		require.InDelta(t, expected, diff.Files[i].ChangeRatio(), 1e-9, i)
+	}
+	require.Zero(t, (&DiffFile{Mode: RENAMED, OrigName: "a", NewName: "b"}).ChangeRatio())
+	require.Zero(t, (&DiffFile{IsBinary: true}).ChangeRatio())
+}
+
+func TestAdditionsAndDeletions(t *testing.T) {
+	// Produced by "git diff -M", alongside the output of "git diff -M
+	// --numstat" for the same change.
+	diff, err := Parse(`diff --git a/del.txt b/del.txt
+deleted file mode 100644
+index b77b4eb..0000000
+--- a/del.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-x
+-y
+diff --git a/img.bin b/img.bin
+index 8352675..ef2caff 100644
+Binary files a/img.bin and b/img.bin differ
+diff --git a/mod.txt b/mod.txt
+index 9405325..91ac79b 100644
+--- a/mod.txt
++++ b/mod.txt
+@@ -1,5 +1,6 @@
+ a
+-b
++B
+ c
+ d
+ e
++f
+diff --git a/new.bin b/new.bin
+new file mode 100644
+index 0000000..f9e371f
+Binary files /dev/null and b/new.bin differ
+diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..5804e55
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,3 @@
++n1
++n2
++n3
+diff --git a/old.txt b/renamed.txt
+similarity index 69%
+rename from old.txt
+rename to renamed.txt
+index 01f84f8..2edf674 100644
+--- a/old.txt
++++ b/renamed.txt
+@@ -7,4 +7,4 @@ l6
+ l7
+ l8
+ l9
+-l10
++l10 changed
+`)
+	require.NoError(t, err)
+	numstat := `0	2	del.txt
+-	-	img.bin
+2	1	mod.txt
+-	-	new.bin
+3	0	new.txt
+1	1	old.txt => renamed.txt
+`
+
+	var stat string
+	for _, f := range diff.Files {
+		added, deleted := strconv.Itoa(f.Additions()), strconv.Itoa(f.Deletions())
+		if f.IsBinary {
+			added, deleted = "-", "-"
+		}
+		name := f.NewName
+		switch f.Mode {
+		case DELETED:
+			name = f.OrigName
+		case RENAMED:
+			name = f.OrigName + " => " + f.NewName
+		}
+		stat += added + "\t" + deleted + "\t" + name + "\n"
+	}
+	require.Equal(t, numstat, stat)
+
+	require.Equal(t, []FileMode{DELETED, MODIFIED, MODIFIED, NEW, NEW, RENAMED}, []FileMode{
+		diff.Files[0].Mode, diff.Files[1].Mode, diff.Files[2].Mode,
+		diff.Files[3].Mode, diff.Files[4].Mode, diff.Files[5].Mode,
+	})
+	require.Equal(t, "img.bin", diff.Files[1].OrigName)
+	require.Equal(t, "", diff.Files[3].OrigName)
+	require.Equal(t, "new.bin", diff.Files[3].NewName)
+}
+
+func TestModeChangeWithEdits(t *testing.T) {
+	input := `diff --git a/s.sh b/s.sh
+old mode 100644
+new mode 100755
+index 422c2b7..0f7bc76
+--- a/s.sh
++++ b/s.sh
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`
+	diff, err := Parse(input)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 1)
+	file := diff.Files[0]
+	require.Equal(t, MODIFIED, file.Mode)
+	require.Equal(t, 0100644, file.OldMode)
+	require.Equal(t, 0100755, file.NewMode)
+	require.Equal(t, "s.sh", file.OrigName)
+	require.Equal(t, "s.sh", file.NewName)
+	require.Len(t, file.Hunks, 1)
+	require.Equal(t, 1, file.Additions())
+	require.Equal(t, 1, file.Deletions())
+	require.Equal(t, input, diff.String())
+
+	_, err = Parse("diff --git a/s.sh b/s.sh\nold mode 10064x\n")
+	require.EqualError(t, err, "s.sh: line 2: invalid file mode: old mode 10064x")
+}
+
+func TestGitBinaryPatch(t *testing.T) {
+	diff, err := Parse(`diff --git a/img.bin b/img.bin
+index 8352675..ef2caff 100644
+GIT binary patch
+literal 4
+LcmZQzWMT#Y01f~L
+
+literal 3
+KcmZQzWMTjS00aO5
+
+`)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 1)
+	require.True(t, diff.Files[0].IsBinary)
+	require.Empty(t, diff.Files[0].Hunks)
+}
+
+func TestBinaryFiles(t *testing.T) {
+	input := `diff --git a/bin1 b/bin1
+index bdc955b..a903574 100644
+Binary files a/bin1 and b/bin1 differ
+diff --git a/new.bin b/new.bin
+new file mode 100644
+index 0000000..d6db588
+Binary files /dev/null and b/new.bin differ
+diff --git a/gone.bin b/gone.bin
+deleted file mode 100644
+index d6db588..0000000
+Binary files a/gone.bin and /dev/null differ
+diff --git a/old.bin b/moved.bin
+similarity index 60%
+rename from old.bin
+rename to moved.bin
+index 1111111..2222222 100644
+Binary files a/old.bin and b/moved.bin differ
+diff --git a/text b/text
+index 3333333..4444444 100644
+--- a/text
++++ b/text
+@@ -1 +1 @@
+-a
++b
+`
+	diff, err := Parse(input)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 5)
+	for i, expected := range []struct {
+		mode              FileMode
+		origName, newName string
+	}{
+		{MODIFIED, "bin1", "bin1"},
+		{NEW, "", "new.bin"},
+		{DELETED, "gone.bin", ""},
+		{RENAMED, "old.bin", "moved.bin"},
+	} {
+		f := diff.Files[i]
+		require.True(t, f.IsBinary, i)
+		require.Empty(t, f.Hunks, i)
+		require.Equal(t, expected.mode, f.Mode, i)
+		require.Equal(t, expected.origName, f.OrigName, i)
+		require.Equal(t, expected.newName, f.NewName, i)
+	}
+	require.False(t, diff.Files[4].IsBinary)
+
+	require.Equal(t, map[string][]int{
+		"bin1":      {},
+		"new.bin":   {},
+		"moved.bin": {},
+		"text":      {1},
+	}, diff.Changed())
+	require.Equal(t, map[string][]int{
+		"bin1":     {},
+		"gone.bin": {},
+		"old.bin":  {},
+		"text":     {1},
+	}, diff.Removed())
+
+	require.Equal(t, input, diff.String())
+}
+
+func TestParseMercurial(t *testing.T) {
+	diff, err := Parse(`diff -r 9117c6561b0b -r 273ce12ad8f1 foo.txt
+--- a/foo.txt	Thu Jan 01 00:00:00 1970 +0000
++++ b/foo.txt	Thu Jan 01 00:00:01 1970 +0000
+@@ -1,1 +1,2 @@
+ a
++b
+diff -r 9117c6561b0b -r 273ce12ad8f1 dir/new file.txt
+--- /dev/null	Thu Jan 01 00:00:00 1970 +0000
++++ b/dir/new file.txt	Thu Jan 01 00:00:01 1970 +0000
+@@ -0,0 +1,1 @@
++new
+diff -r 9117c6561b0b gone.txt
+--- a/gone.txt	Thu Jan 01 00:00:00 1970 +0000
++++ /dev/null	Thu Jan 01 00:00:00 1970 +0000
+@@ -1,1 +0,0 @@
+-old
+diff -r 9117c6561b0b img.png
+Binary file img.png has changed
+`)
+	require.NoError(t, err)
+	require.NoError(t, diff.Validate())
+	require.Len(t, diff.Files, 4)
+	for i, expected := range []struct {
+		mode              FileMode
+		origName, newName string
+		binary            bool
+	}{
+		{MODIFIED, "foo.txt", "foo.txt", false},
+		{NEW, "", "dir/new file.txt", false},
+		{DELETED, "gone.txt", "", false},
+		{MODIFIED, "img.png", "img.png", true},
+	} {
+		f := diff.Files[i]
+		require.Equal(t, expected.mode, f.Mode, i)
+		require.Equal(t, expected.origName, f.OrigName, i)
+		require.Equal(t, expected.newName, f.NewName, i)
+		require.Equal(t, expected.binary, f.IsBinary, i)
+	}
+	require.Equal(t, "diff -r 9117c6561b0b -r 273ce12ad8f1 foo.txt", diff.Files[0].Command)
+	require.Equal(t, map[string][]int{
+		"foo.txt":          {2},
+		"dir/new file.txt": {1},
+		"img.png":          {},
+	}, diff.Changed())
+
+	// Mercurial's binary line is a file header line.
+	_, err = Parse("Binary file img.png has changed\n")
+	require.True(t, errors.Is(err, ErrHeaderBeforeFile))
+}
+
+func TestBinaryFileNames(t *testing.T) {
+	input := `diff --git a/my file.bin b/my file.bin
+index 1111111..2222222 100644
+Binary files a/my file.bin and b/my file.bin differ
+diff --git a/cats and dogs.bin b/cats and dogs.bin
+new file mode 100644
+index 0000000..8835708
+Binary files /dev/null and b/cats and dogs.bin differ
+diff --git a/cats and dogs.bin b/cats and dogs.bin
+index 8835708..3333333 100644
+Binary files a/cats and dogs.bin and b/cats and dogs.bin differ
+diff --git "a/tab\tx.bin" "b/tab\tx.bin"
+deleted file mode 100644
+index a903574..0000000
+Binary files "a/tab\tx.bin" and /dev/null differ
+`
+	diff, err := Parse(input)
+	require.NoError(t, err)
+	for i, expected := range []struct {
+		mode              FileMode
+		origName, newName string
+	}{
+		{MODIFIED, "my file.bin", "my file.bin"},
+		{NEW, "", "cats and dogs.bin"},
+		{MODIFIED, "cats and dogs.bin", "cats and dogs.bin"},
+		{DELETED, "tab\tx.bin", ""},
+	} {
+		f := diff.Files[i]
+		require.True(t, f.IsBinary, i)
+		require.Equal(t, expected.mode, f.Mode, i)
+		require.Equal(t, expected.origName, f.OrigName, i)
+		require.Equal(t, expected.newName, f.NewName, i)
+	}
+	require.Equal(t, input, diff.String())
+
+	// Without names from the "diff" line, the binary line is split where
+	// it can be told apart.
+	for s, expected := range map[string][2]string{
+		"a/x y and b/x y":               {"x y", "x y"},
+		"a/x and y and b/x and y":       {"x and y", "x and y"},
+		`a/x and y and "b/x\ty"`:        {"x and y", "x\ty"},
+		`"a/x and y" and b/z`:           {"x and y", "z"},
+		"a/one and two and b/three":     {"one and two", "three"},
+		"a/one and b/two and b/three":   {"", ""},
+		"/dev/null and b/new and more":  {"/dev/null", "new and more"},
+		"a/old and more and /dev/null":  {"old and more", "/dev/null"},
+		"a/x and y and b/x and y and z": {"x and y", "x and y and z"},
+	} {
+		orig, new, ok := parseBinaryNames(s, "", "")
+		require.Equal(t, expected[0] != "", ok, s)
+		require.Equal(t, expected, [2]string{orig, new}, s)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	byt, err := ioutil.ReadFile("example.diff")
+	require.NoError(t, err)
+
+	diff, err := ParseBytes(byt)
+	require.NoError(t, err)
+	require.Equal(t, setup(t), diff)
+
+	// The Diff aliases the bytes rather than copying them.
+	require.True(t, unsafe.SliceData(byt) == unsafe.StringData(diff.Raw))
+
+	diff, err = ParseBytes(nil)
+	require.NoError(t, err)
+	require.Empty(t, diff.Files)
+}
+
+func TestParseBeforeFileHeader(t *testing.T) {
+	for _, test := range []struct {
+		diff string
+		err  string
+	}{
+		{
+			diff: "@@ -1 +1 @@\n-a\n+b\n",
+			err:  "line 1: hunk before file header: @@ -1 +1 @@",
+		}, {
+			diff: "@@@ -1 -1 +1 @@@\n--a\n++b\n",
+			err:  "line 1: hunk before file header: @@@ -1 -1 +1 @@@",
+		}, {
+			diff: "+++ /dev/null\n@@ -1 +0,0 @@\n-a\n",
+			err:  `line 1: file header line before "diff" line: +++ /dev/null`,
+		}, {
+			diff: "--- a/file\n+++ b/file\n",
+			err:  `line 1: file header line before "diff" line: --- a/file`,
+		}, {
+			diff: "similarity index 90%\nrename from a\nrename to b\n",
+			err:  `line 1: file header line before "diff" line: similarity index 90%`,
+		}, {
+			diff: "Binary files a/x and b/x differ\n",
+			err:  `line 1: file header line before "diff" line: Binary files a/x and b/x differ`,
+		},
+	} {
+		_, err := Parse(test.diff)
+		require.EqualError(t, err, test.err)
+	}
+
+	// Other text before the first file is ignored.
+	diff, err := Parse("Some commit message.\n\nrename the thing\ndiff --git a/file b/file\n")
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 1)
+}
+
+func TestParseFile(t *testing.T) {
+	_, err := ParseFile("missing.diff")
+	require.Error(t, err)
+	require.True(t, os.IsNotExist(errors.Unwrap(err)))
+	require.Contains(t, err.Error(), "missing.diff")
+
+	path := filepath.Join(t.TempDir(), "bad.diff")
+	require.NoError(t, os.WriteFile(path, []byte("diff --git a/x b/x\n@@ -a +b @@\n"), 0644))
+	_, err = ParseFile(path)
+	require.EqualError(t, err, path+": x: line 2: invalid hunk header: @@ -a +b @@")
+	require.True(t, errors.Is(err, ErrInvalidHunkHeader))
+}
+
+func BenchmarkParse(b *testing.B) {
+	input, err := os.ReadFile("example.diff")
+	require.NoError(b, err)
+	diff := string(input)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(diff); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParser(b *testing.B) {
+	input, err := os.ReadFile("example.diff")
+	require.NoError(b, err)
+	diff := string(input)
+	p := NewParser()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Parse(diff); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParserReuse(t *testing.T) {
+	example, err := os.ReadFile("example.diff")
+	require.NoError(t, err)
+	p := NewParser(WithSlashPaths())
+	var parsed []*Diff
+	for _, input := range []string{
+		string(example),
+		mergeOther,
+		"diff --git a/dir\\f b/dir\\f\n--- a/dir\\f\n+++ b/dir\\f\n@@ -1 +1 @@\n-x\n+y\n",
+	} {
+		diff, err := p.Parse(input)
+		require.NoError(t, err)
+		expected, err := Parse(input, WithSlashPaths())
+		require.NoError(t, err)
+		require.Equal(t, expected, diff)
+		parsed = append(parsed, diff)
+	}
+	require.Equal(t, "dir/f", parsed[2].Files[0].NewName)
+
+	// Diffs parsed earlier are left as they were.
+	require.Equal(t, setup(t), parsed[0])
+	require.Equal(t, "y", parsed[1].Files[0].Hunks[0].NewRange.Lines[0].Content)
+
+	_, err = p.Parse("@@ -1 +1 @@\n")
+	require.Error(t, err)
+	diff, err := p.Parse(mergeOther)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 1)
+}
+
+func TestRawSpan(t *testing.T) {
+	diff := setup(t)
+	var files string
+	for _, f := range diff.Files {
+		raw := diff.Raw[f.RawSpan.Start:f.RawSpan.End]
+		require.True(t, strings.HasPrefix(raw, f.Command+"\n"), raw)
+		files += raw
+		for _, h := range f.Hunks {
+			require.True(t, h.RawSpan.Start >= f.RawSpan.Start && h.RawSpan.End <= f.RawSpan.End)
+			require.True(t, strings.HasPrefix(diff.Raw[h.RawSpan.Start:h.RawSpan.End], "@@ "))
+		}
+	}
+	// The files cover the diff, bar the blank lines after it.
+	require.Equal(t, diff.Raw, files+diff.Trailing)
+
+	for _, test := range []struct {
+		name  string
+		input string
+		files []string
+		hunks [][]string
+	}{{
+		name:  "crlf",
+		input: "diff --git a/f b/f\r\n--- a/f\r\n+++ b/f\r\n@@ -1 +1 @@\r\n-a\r\n+b\r\ndiff --git a/g b/g\r\n--- a/g\r\n+++ b/g\r\n@@ -1 +1 @@\r\n-c\r\n+d\r\n",
+		files: []string{
+			"diff --git a/f b/f\r\n--- a/f\r\n+++ b/f\r\n@@ -1 +1 @@\r\n-a\r\n+b\r\n",
+			"diff --git a/g b/g\r\n--- a/g\r\n+++ b/g\r\n@@ -1 +1 @@\r\n-c\r\n+d\r\n",
+		},
+		hunks: [][]string{{"@@ -1 +1 @@\r\n-a\r\n+b\r\n"}, {"@@ -1 +1 @@\r\n-c\r\n+d\r\n"}},
+	}, {
+		name:  "no trailing newline",
+		input: "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n@@ -5 +5 @@\n-c\n+d",
+		files: []string{"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n@@ -5 +5 @@\n-c\n+d"},
+		hunks: [][]string{{"@@ -1 +1 @@\n-a\n+b\n", "@@ -5 +5 @@\n-c\n+d"}},
+	}, {
+		name:  "no newline marker and trailing blank lines",
+		input: "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n\\ No newline at end of file\n\n\n",
+		files: []string{"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n\\ No newline at end of file\n"},
+		hunks: [][]string{{"@@ -1 +1 @@\n-a\n+b\n\\ No newline at end of file\n"}},
+	}} {
+		diff, err := Parse(test.input)
+		require.NoError(t, err, test.name)
+		require.Len(t, diff.Files, len(test.files), test.name)
+		for i, f := range diff.Files {
+			require.Equal(t, test.files[i], diff.Raw[f.RawSpan.Start:f.RawSpan.End], test.name)
+			require.Len(t, f.Hunks, len(test.hunks[i]), test.name)
+			for j, h := range f.Hunks {
+				require.Equal(t, test.hunks[i][j], diff.Raw[h.RawSpan.Start:h.RawSpan.End], test.name)
+			}
+		}
+	}
+
+	// Spans are offsets in the input, whatever the options strip from it.
+	input := "> diff --git a/f b/f\n> --- a/f\n> +++ b/f\n> @@ -1 +1 @@\n> -a\n> +b\n"
+	diff, err := Parse(input, WithStripPrefix("> "))
+	require.NoError(t, err)
+	require.Equal(t, RawSpan{Start: 0, End: len(input)}, diff.Files[0].RawSpan)
+	require.Equal(t, "> @@ -1 +1 @@\n> -a\n> +b\n", input[diff.Files[0].Hunks[0].RawSpan.Start:diff.Files[0].Hunks[0].RawSpan.End])
+}
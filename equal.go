@@ -0,0 +1,149 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"fmt"
+	"slices"
+)
+
+// EqualOption configures how Diff.Equal and Diff.DiffAgainst compare diffs.
+type EqualOption func(*equalOptions)
+
+type equalOptions struct {
+	ignoreRaw       bool
+	ignorePositions bool
+}
+
+// IgnoreRaw leaves the text the diffs were parsed from out of the
+// comparison: Raw and Trailing, each file's DiffHeader, Command and
+// ExtendedHeaders, the RawSpan of each file and hunk and the Offset and Length
+// of each line. Diffs holding the same changes then compare equal even if
+// they were written differently, e.g. with other "index" lines.
+func IgnoreRaw() EqualOption {
+	return func(o *equalOptions) {
+		o.ignoreRaw = true
+	}
+}
+
+// IgnorePositions leaves the lines' positions in the diff out of the
+// comparison.
+func IgnorePositions() EqualOption {
+	return func(o *equalOptions) {
+		o.ignorePositions = true
+	}
+}
+
+// Equal reports whether d and other hold the same files, hunks and lines,
+// as DiffAgainst finds no differences.
+func (d *Diff) Equal(other *Diff, opts ...EqualOption) bool {
+	return len(d.DiffAgainst(other, opts...)) == 0
+}
+
+// DiffAgainst compares d with other field by field and describes each
+// difference, e.g. `file 3, hunk 2, new line 7: Content: "a" != "b"`, with
+// d's value first. Files, hunks and lines are numbered from 1. Lines are
+// compared by value, in each of a hunk's ranges, so the lines the ranges share
+// don't matter, and nil and empty slices are the same. When the number of
+// files, hunks or lines differs, those past the shorter are not compared.
+func (d *Diff) DiffAgainst(other *Diff, opts ...EqualOption) []string {
+	var o equalOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c := &comparer{opts: o}
+	if !o.ignoreRaw {
+		c.compare("", "Raw", d.Raw, other.Raw)
+		c.compare("", "Trailing", d.Trailing, other.Trailing)
+	}
+	c.compare("", "PullID", d.PullID, other.PullID)
+	c.compare("", "files", len(d.Files), len(other.Files))
+	for i := range min(len(d.Files), len(other.Files)) {
+		c.compareFiles(fmt.Sprintf("file %d", i+1), d.Files[i], other.Files[i])
+	}
+	return c.diffs
+}
+
+// comparer collects the differences found between two diffs.
+type comparer struct {
+	opts  equalOptions
+	diffs []string
+}
+
+// compare records a difference in the named field at where if a and b
+// differ.
+func (c *comparer) compare(where, field string, a, b any) {
+	if a == b {
+		return
+	}
+	if where != "" {
+		where += ": "
+	}
+	if _, ok := a.(string); ok {
+		c.diffs = append(c.diffs, fmt.Sprintf("%s%s: %q != %q", where, field, a, b))
+		return
+	}
+	c.diffs = append(c.diffs, fmt.Sprintf("%s%s: %v != %v", where, field, a, b))
+}
+
+func (c *comparer) compareFiles(where string, a, b *DiffFile) {
+	if !c.opts.ignoreRaw {
+		c.compare(where, "DiffHeader", a.DiffHeader, b.DiffHeader)
+		c.compare(where, "Command", a.Command, b.Command)
+		if !slices.Equal(a.ExtendedHeaders, b.ExtendedHeaders) {
+			c.compare(where, "ExtendedHeaders", fmt.Sprint(a.ExtendedHeaders), fmt.Sprint(b.ExtendedHeaders))
+		}
+		c.compare(where, "RawSpan", a.RawSpan, b.RawSpan)
+	}
+	c.compare(where, "Mode", a.Mode, b.Mode)
+	c.compare(where, "OrigName", a.OrigName, b.OrigName)
+	c.compare(where, "NewName", a.NewName, b.NewName)
+	c.compare(where, "IsBinary", a.IsBinary, b.IsBinary)
+	c.compare(where, "SimilarityIndex", a.SimilarityIndex, b.SimilarityIndex)
+	c.compare(where, "OldMode", a.OldMode, b.OldMode)
+	c.compare(where, "NewMode", a.NewMode, b.NewMode)
+	c.compare(where, "OrigNoNewlineAtEOF", a.OrigNoNewlineAtEOF, b.OrigNoNewlineAtEOF)
+	c.compare(where, "NewNoNewlineAtEOF", a.NewNoNewlineAtEOF, b.NewNoNewlineAtEOF)
+	c.compare(where, "hunks", len(a.Hunks), len(b.Hunks))
+	for i := range min(len(a.Hunks), len(b.Hunks)) {
+		c.compareHunks(fmt.Sprintf("%s, hunk %d", where, i+1), a.Hunks[i], b.Hunks[i])
+	}
+}
+
+func (c *comparer) compareHunks(where string, a, b *DiffHunk) {
+	c.compare(where, "HunkHeader", a.HunkHeader, b.HunkHeader)
+	if !c.opts.ignoreRaw {
+		c.compare(where, "RawSpan", a.RawSpan, b.RawSpan)
+	}
+	c.compareRanges(where, "orig", a.OrigRange, b.OrigRange)
+	c.compareRanges(where, "new", a.NewRange, b.NewRange)
+	c.compareRanges(where, "whole", a.WholeRange, b.WholeRange)
+	c.compare(where, "parent ranges", len(a.ParentRanges), len(b.ParentRanges))
+	for i := range min(len(a.ParentRanges), len(b.ParentRanges)) {
+		c.compareRanges(where, fmt.Sprintf("parent %d", i+1), a.ParentRanges[i], b.ParentRanges[i])
+	}
+}
+
+func (c *comparer) compareRanges(where, name string, a, b DiffRange) {
+	c.compare(where, name+" Start", a.Start, b.Start)
+	c.compare(where, name+" Length", a.Length, b.Length)
+	c.compare(where, name+" lines", len(a.Lines), len(b.Lines))
+	for i := range min(len(a.Lines), len(b.Lines)) {
+		lineWhere := fmt.Sprintf("%s, %s line %d", where, name, i+1)
+		la, lb := a.Lines[i], b.Lines[i]
+		c.compare(lineWhere, "Mode", la.Mode, lb.Mode)
+		c.compare(lineWhere, "Number", la.Number, lb.Number)
+		c.compare(lineWhere, "Content", la.Content, lb.Content)
+		if !c.opts.ignorePositions {
+			c.compare(lineWhere, "Position", la.Position, lb.Position)
+		}
+		if !c.opts.ignoreRaw {
+			c.compare(lineWhere, "Offset", la.Offset, lb.Offset)
+			c.compare(lineWhere, "Length", la.Length, lb.Length)
+		}
+		if !slices.Equal(la.ParentModes, lb.ParentModes) {
+			c.compare(lineWhere, "ParentModes", fmt.Sprint(la.ParentModes), fmt.Sprint(lb.ParentModes))
+		}
+	}
+}
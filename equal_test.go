@@ -0,0 +1,59 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEqual(t *testing.T) {
+	diff := setup(t)
+	require.True(t, diff.Equal(diff))
+	require.True(t, diff.Equal(diff.Clone()))
+	require.Empty(t, diff.DiffAgainst(diff.Clone()))
+
+	// The same changes written with other headers.
+	reparsed, err := Parse(strings.Replace(diff.String(), "index 504d2a1..50ccec3 100644", "index 1111111..2222222", 1))
+	require.NoError(t, err)
+	require.False(t, diff.Equal(reparsed))
+	require.True(t, diff.Equal(reparsed, IgnoreRaw()))
+}
+
+func TestDiffAgainst(t *testing.T) {
+	diff := setup(t)
+	other := diff.Clone()
+	other.Files[0].Mode = RENAMED
+	other.Files[0].Hunks[0].NewRange.Lines[1].Content = "changed"
+	other.Files[2].Hunks = nil
+	other.Files = other.Files[:4]
+	other.Files[1].Hunks[0].WholeRange.Lines[0].Position++
+
+	require.False(t, diff.Equal(other))
+	require.Equal(t, []string{
+		"files: 6 != 4",
+		"file 1: Mode: modified != renamed",
+		`file 1, hunk 1, new line 2: Content: "some" != "changed"`,
+		`file 1, hunk 1, whole line 2: Content: "some" != "changed"`,
+		"file 2, hunk 1, orig line 1: Position: 1 != 2",
+		"file 2, hunk 1, whole line 1: Position: 1 != 2",
+		"file 3: hunks: 1 != 0",
+	}, diff.DiffAgainst(other, IgnoreRaw()))
+
+	other = diff.Clone()
+	other.Raw = ""
+	other.Files[1].Hunks[0].WholeRange.Lines[0].Position = 9
+	require.Equal(t, []string{fmt.Sprintf(`Raw: %q != ""`, diff.Raw)}, diff.DiffAgainst(other, IgnorePositions()))
+	require.True(t, diff.Equal(other, IgnoreRaw(), IgnorePositions()))
+}
+
+func TestEqualNilAndEmpty(t *testing.T) {
+	a := &Diff{Files: []*DiffFile{{Mode: NEW, NewName: "f", Hunks: []*DiffHunk{}}}}
+	b := &Diff{Files: []*DiffFile{{Mode: NEW, NewName: "f"}}}
+	require.True(t, a.Equal(b))
+	require.True(t, (&Diff{}).Equal(&Diff{Files: []*DiffFile{}}))
+}
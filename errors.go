@@ -0,0 +1,88 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors Parse returns wrap one of these, with the offending line, in a
+// ParseError, so callers can tell them apart with errors.Is.
+var (
+	// ErrInvalidHunkHeader is returned for a malformed "@@" line.
+	ErrInvalidHunkHeader = errors.New("invalid hunk header")
+
+	// ErrInvalidLine is returned for a line in a hunk that doesn't start
+	// with " ", "+" or "-".
+	ErrInvalidLine = errors.New("invalid line")
+
+	// ErrInvalidBinaryDiff is returned for a "Binary files ... differ"
+	// line whose names can't be read.
+	ErrInvalidBinaryDiff = errors.New("invalid binary diff")
+
+	// ErrInvalidRename is returned for a rename summary line that can't be
+	// read.
+	ErrInvalidRename = errors.New("invalid rename")
+
+	// ErrInvalidFileMode is returned for a mode header whose mode isn't an
+	// octal number.
+	ErrInvalidFileMode = errors.New("invalid file mode")
+
+	// ErrInvalidSimilarityIndex is returned for a "similarity index" line
+	// whose percentage isn't a number from 0 to 100.
+	ErrInvalidSimilarityIndex = errors.New("invalid similarity index")
+
+	// ErrLineOutsideHunk is returned for a line on a side of a hunk whose
+	// header gives that side no lines, such as an added line in a hunk
+	// whose new range has length 0.
+	ErrLineOutsideHunk = errors.New("line outside hunk range")
+
+	// ErrHunkOutOfOrder is returned, with WithStrictHunkOrder, for a hunk
+	// that starts before the previous hunk of the file ends.
+	ErrHunkOutOfOrder = errors.New("hunk out of order")
+
+	// ErrHunkBeforeFile is returned for a hunk before any "diff" line.
+	ErrHunkBeforeFile = errors.New("hunk before file header")
+
+	// ErrHeaderBeforeFile is returned for a file header line, such as
+	// "---", before any "diff" line.
+	ErrHeaderBeforeFile = errors.New(`file header line before "diff" line`)
+)
+
+// ParseError is the error Parse returns for a line of the diff it can't
+// read. It wraps one of the errors above.
+type ParseError struct {
+	// Line is the 1-based number of the offending line in the input.
+	Line int
+	// File is the name of the file being parsed, or "" if the line comes
+	// before the first file.
+	File string
+	// Err is the error for the line.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: line %d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError returns a ParseError for err at the 0-based line idx of file,
+// which may be nil.
+func newParseError(idx int, file *DiffFile, err error) *ParseError {
+	e := &ParseError{Line: idx + 1, Err: err}
+	if file != nil {
+		e.File = file.NewName
+		if e.File == "" {
+			e.File = file.OrigName
+		}
+	}
+	return e
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorKinds(t *testing.T) {
+	header := "diff --git a/f b/f\n--- a/f\n+++ b/f\n"
+	for _, test := range []struct {
+		diff     string
+		expected error
+		message  string
+	}{{
+		diff:     header + "@@ -99999999999999999999 +1 @@\n",
+		expected: ErrInvalidHunkHeader,
+		message:  "f: line 4: invalid hunk header: @@ -99999999999999999999 +1 @@",
+	}, {
+		diff:     header + "@@@ -1 +1 @@@\n",
+		expected: ErrInvalidHunkHeader,
+		message:  "f: line 4: invalid hunk header: @@@ -1 +1 @@@",
+	}, {
+		diff:     header + "@@@ -1 -1 +1 @@@\n*a\n",
+		expected: ErrInvalidLine,
+		message:  `f: line 5: invalid line: "*a"`,
+	}, {
+		diff:     header + "@@ -5,2 +5,0 @@\n-a\n+b\n",
+		expected: ErrLineOutsideHunk,
+		message:  "f: line 6: line outside hunk range: +b",
+	}, {
+		diff:     header + "@@ -5,0 +6,2 @@\n+a\n a\n",
+		expected: ErrLineOutsideHunk,
+		message:  "f: line 6: line outside hunk range:  a",
+	}, {
+		diff:     "diff --git a/f b/f\nBinary files a/f differ\n",
+		expected: ErrInvalidBinaryDiff,
+		message:  "f: line 2: invalid binary diff: Binary files a/f differ",
+	}, {
+		diff:     "diff --git a/f b/f\nrename nonsense\n",
+		expected: ErrInvalidRename,
+		message:  "f: line 2: invalid rename: rename nonsense",
+	}, {
+		diff:     "diff --git a/f b/f\nnew file mode 9\n",
+		expected: ErrInvalidFileMode,
+		message:  "f: line 2: invalid file mode: new file mode 9",
+	}, {
+		diff:     "diff --git a/f b/g\nsimilarity index 9x%\nrename from f\nrename to g\n",
+		expected: ErrInvalidSimilarityIndex,
+		message:  "g: line 2: invalid similarity index: similarity index 9x%",
+	}, {
+		diff:     "diff --git a/f b/g\nsimilarity index 101%\n",
+		expected: ErrInvalidSimilarityIndex,
+		message:  "g: line 2: invalid similarity index: similarity index 101%",
+	}, {
+		diff:     "@@ -1 +1 @@\n",
+		expected: ErrHunkBeforeFile,
+		message:  "line 1: hunk before file header: @@ -1 +1 @@",
+	}, {
+		diff:     "--- a/f\n",
+		expected: ErrHeaderBeforeFile,
+		message:  `line 1: file header line before "diff" line: --- a/f`,
+	}, {
+		diff:     "old mode 100644\nnew mode 100755\n",
+		expected: ErrHeaderBeforeFile,
+		message:  `line 1: file header line before "diff" line: old mode 100644`,
+	}} {
+		_, err := Parse(test.diff)
+		require.Error(t, err, test.diff)
+		require.True(t, errors.Is(err, test.expected), "%q: %v", test.diff, err)
+		require.EqualError(t, err, test.message)
+	}
+}
+
+func TestParseErrorLine(t *testing.T) {
+	diff := `diff --git a/ok b/ok
+--- a/ok
++++ b/ok
+@@ -1 +1 @@
+-a
++b
+diff --git a/bad b/bad
+--- a/bad
++++ b/bad
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+@@ -x +y @@
+`
+	_, err := Parse(diff)
+	var parseErr *ParseError
+	require.True(t, errors.As(err, &parseErr))
+	require.Equal(t, 14, parseErr.Line)
+	require.Equal(t, "bad", parseErr.File)
+	require.True(t, errors.Is(err, ErrInvalidHunkHeader))
+	require.EqualError(t, err, "bad: line 14: invalid hunk header: @@ -x +y @@")
+
+	_, err = Parse("\n@@ -1 +1 @@\n")
+	require.True(t, errors.As(err, &parseErr))
+	require.Equal(t, 2, parseErr.Line)
+	require.Empty(t, parseErr.File)
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"path"
+	"strings"
+)
+
+// FilterPaths returns a diff holding only the files whose path matches one of
+// the include patterns, or any path if include is empty, and none of the
+// exclude patterns. A file's path is its NewName, or OrigName if it was
+// deleted; a renamed file matches if either name does.
+//
+// Patterns are matched against the whole path, segment by segment, as with
+// path.Match, except that a "**" segment matches any number of directories,
+// e.g. "pkg/**" or "**/*_test.go". Invalid patterns match nothing.
+//
+// The files are shared with d, which is left as it was, and Raw is
+// regenerated from them.
+func (d *Diff) FilterPaths(include, exclude []string) *Diff {
+	includePatterns := splitPatterns(include)
+	excludePatterns := splitPatterns(exclude)
+	return d.Filter(func(f *DiffFile) bool {
+		names := f.paths()
+		if len(include) > 0 && !matchAny(includePatterns, names) {
+			return false
+		}
+		return !matchAny(excludePatterns, names)
+	})
+}
+
+// Filter returns a diff holding only the files for which keep returns true.
+// The files are shared with d, which is left as it was, and Raw is
+// regenerated from them.
+func (d *Diff) Filter(keep func(*DiffFile) bool) *Diff {
+	filtered := &Diff{PullID: d.PullID}
+	for _, f := range d.Files {
+		if keep(f) {
+			filtered.Files = append(filtered.Files, f)
+		}
+	}
+	filtered.Raw = filtered.String()
+	return filtered
+}
+
+// FilterOptions configures Diff.FilterHunks.
+type FilterOptions struct {
+	// DropEmptyFiles leaves out files that had hunks but have none left.
+	// Files that never had any, such as renames without changes, are kept.
+	DropEmptyFiles bool
+}
+
+// FilterHunks returns a diff in which each file only holds the hunks for
+// which keep returns true, as by DiffFile.FilterHunks. Raw is regenerated and
+// d is left as it was.
+func (d *Diff) FilterHunks(keep func(*DiffFile, *DiffHunk) bool, opts FilterOptions) *Diff {
+	filtered := &Diff{PullID: d.PullID}
+	for _, f := range d.Files {
+		c := f.FilterHunks(func(h *DiffHunk) bool {
+			return keep(f, h)
+		})
+		if opts.DropEmptyFiles && len(f.Hunks) > 0 && len(c.Hunks) == 0 {
+			continue
+		}
+		filtered.Files = append(filtered.Files, c)
+	}
+	filtered.Raw = filtered.String()
+	return filtered
+}
+
+// FilterHunks returns a copy of the file holding copies of the hunks for
+// which keep returns true. The hunks' ranges and line numbers are kept, so
+// the result still applies to the original file, but the lines' positions
+// are recomputed for the hunks that remain. The no-newline flags are cleared
+// if the last hunk, which they belong to, is left out.
+func (f *DiffFile) FilterHunks(keep func(*DiffHunk) bool) *DiffFile {
+	c := *f
+	c.Hunks = nil
+	for i, h := range f.Hunks {
+		if !keep(h) {
+			if i == len(f.Hunks)-1 {
+				c.OrigNoNewlineAtEOF = false
+				c.NewNoNewlineAtEOF = false
+			}
+			continue
+		}
+		c.Hunks = append(c.Hunks, h.Clone())
+	}
+	c.renumberPositions()
+	return &c
+}
+
+// renumberPositions sets the position of each line as Parse would for the
+// diff String writes.
+func (f *DiffFile) renumberPositions() {
+	var pos int
+	for i, h := range f.Hunks {
+		if i > 0 {
+			// The hunk header takes a position.
+			pos++
+		}
+		lastOrig, lastNew := -1, -1
+		if i == len(f.Hunks)-1 {
+			for j, l := range h.WholeRange.Lines {
+				if l.Mode != ADDED && f.OrigNoNewlineAtEOF {
+					lastOrig = j
+				}
+				if l.Mode != REMOVED && f.NewNoNewlineAtEOF {
+					lastNew = j
+				}
+			}
+		}
+		for j, p := range h.Pairs() {
+			pos++
+			if p.Orig != nil {
+				p.Orig.Position = pos
+			}
+			if p.New != nil {
+				p.New.Position = pos
+			}
+			if j == lastOrig || j == lastNew {
+				// So does the no-newline marker.
+				pos++
+			}
+		}
+	}
+}
+
+// paths returns the names of the file, split into segments, that path
+// filters are matched against.
+func (f *DiffFile) paths() [][]string {
+	switch {
+	case f.Mode == DELETED || f.NewName == "":
+		return [][]string{strings.Split(f.OrigName, "/")}
+	case f.Mode == RENAMED && f.OrigName != f.NewName:
+		return [][]string{strings.Split(f.OrigName, "/"), strings.Split(f.NewName, "/")}
+	}
+	return [][]string{strings.Split(f.NewName, "/")}
+}
+
+func splitPatterns(patterns []string) [][]string {
+	split := make([][]string, len(patterns))
+	for i, p := range patterns {
+		split[i] = strings.Split(p, "/")
+	}
+	return split
+}
+
+func matchAny(patterns, names [][]string) bool {
+	for _, p := range patterns {
+		for _, n := range names {
+			if matchSegments(p, n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchSegments reports whether the path segments in name match those in
+// pattern, where a "**" segment matches zero or more segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterPaths(t *testing.T) {
+	var input strings.Builder
+	for _, name := range []string{
+		"main.go",
+		"main_test.go",
+		"pkg/a.go",
+		"pkg/a_test.go",
+		"pkg/sub/b.go",
+		"vendor/pkg/c.go",
+		"docs/readme.md",
+	} {
+		input.WriteString("diff --git a/" + name + " b/" + name + "\n--- a/" + name + "\n+++ b/" + name + "\n@@ -1 +1 @@\n-a\n+b\n")
+	}
+	input.WriteString("diff --git a/old/d.go b/pkg/d.go\nsimilarity index 90%\nrename from old/d.go\nrename to pkg/d.go\n")
+	input.WriteString("diff --git a/pkg/gone.go b/pkg/gone.go\ndeleted file mode 100644\n--- a/pkg/gone.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-a\n")
+	diff, err := Parse(input.String())
+	require.NoError(t, err)
+	orig := diff.String()
+
+	names := func(d *Diff) []string {
+		var names []string
+		for _, f := range d.Files {
+			if f.NewName != "" {
+				names = append(names, f.NewName)
+			} else {
+				names = append(names, f.OrigName)
+			}
+		}
+		return names
+	}
+
+	for _, test := range []struct {
+		about            string
+		include, exclude []string
+		expected         []string
+	}{{
+		about:    "no patterns",
+		expected: names(diff),
+	}, {
+		about:    "include a directory",
+		include:  []string{"pkg/**"},
+		expected: []string{"pkg/a.go", "pkg/a_test.go", "pkg/sub/b.go", "pkg/d.go", "pkg/gone.go"},
+	}, {
+		about:    "include and exclude",
+		include:  []string{"pkg/**"},
+		exclude:  []string{"**/*_test.go", "pkg/sub/**"},
+		expected: []string{"pkg/a.go", "pkg/d.go", "pkg/gone.go"},
+	}, {
+		about:    "a single star doesn't cross directories",
+		include:  []string{"*.go"},
+		expected: []string{"main.go", "main_test.go"},
+	}, {
+		about:    "exclude only",
+		exclude:  []string{"vendor/**", "**/*.md", "**/*_test.go"},
+		expected: []string{"main.go", "pkg/a.go", "pkg/sub/b.go", "pkg/d.go", "pkg/gone.go"},
+	}, {
+		about:    "either side of a rename",
+		include:  []string{"old/*"},
+		expected: []string{"pkg/d.go"},
+	}, {
+		about:    "invalid patterns match nothing",
+		include:  []string{"[", "main.go"},
+		expected: []string{"main.go"},
+	}} {
+		t.Run(test.about, func(t *testing.T) {
+			filtered := diff.FilterPaths(test.include, test.exclude)
+			require.Equal(t, test.expected, names(filtered))
+			require.Equal(t, filtered.String(), filtered.Raw)
+		})
+	}
+	require.Equal(t, orig, diff.String())
+	require.Len(t, diff.Files, 9)
+}
+
+func TestFilter(t *testing.T) {
+	diff := setup(t)
+	filtered := diff.Filter(func(f *DiffFile) bool {
+		return f.Mode != DELETED
+	})
+	require.Len(t, filtered.Files, 3)
+	require.Equal(t, diff.Files[0], filtered.Files[0])
+	require.Equal(t, diff.Files[3], filtered.Files[1])
+	require.Equal(t, diff.Files[4], filtered.Files[2])
+	require.Equal(t, filtered.String(), filtered.Raw)
+	require.Len(t, diff.Files, 6)
+}
+
+func TestFilterHunks(t *testing.T) {
+	diff, err := Parse(`diff --git a/a.txt b/a.txt
+index 0a1b2c3..4d5e6f7 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+@@ -10,2 +10,3 @@ section
+ ten
++ten and a half
+ eleven
+@@ -20,2 +21,2 @@
+ twenty
+-end
+\ No newline at end of file
++END
+\ No newline at end of file
+diff --git a/b.txt b/b.txt
+index 1111111..2222222 100644
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1 @@
+-b
++B
+diff --git a/old b/new
+similarity index 100%
+rename from old
+rename to new
+`)
+	require.NoError(t, err)
+	orig := diff.String()
+
+	// Keep the hunks that add lines.
+	keep := func(f *DiffFile, h *DiffHunk) bool {
+		return f.NewName == "a.txt" && h.NewRange.Start == 10
+	}
+	filtered := diff.FilterHunks(keep, FilterOptions{})
+	require.Len(t, filtered.Files, 3)
+	require.Empty(t, filtered.Files[1].Hunks)
+	require.Empty(t, filtered.Files[2].Hunks)
+
+	a := filtered.Files[0]
+	require.Len(t, a.Hunks, 1)
+	require.False(t, a.OrigNoNewlineAtEOF)
+	require.False(t, a.NewNoNewlineAtEOF)
+	require.Equal(t, 10, a.Hunks[0].NewRange.Start)
+	var positions []int
+	for l := range a.Lines() {
+		positions = append(positions, l.Position)
+	}
+	require.Equal(t, []int{1, 2, 3}, positions)
+	require.Equal(t, 1, a.Hunks[0].OrigRange.Lines[0].Position)
+
+	reparsed, err := Parse(filtered.Raw)
+	require.NoError(t, err)
+	requireEquivalent(t, filtered, reparsed)
+
+	// Files emptied by the filter can be dropped.
+	filtered = diff.FilterHunks(keep, FilterOptions{DropEmptyFiles: true})
+	require.Len(t, filtered.Files, 2)
+	require.Equal(t, "new", filtered.Files[1].NewName)
+
+	// Keeping the last hunk keeps the no-newline markers.
+	last := diff.Files[0].FilterHunks(func(h *DiffHunk) bool {
+		return h.NewRange.Start != 10
+	})
+	require.Len(t, last.Hunks, 2)
+	require.True(t, last.NewNoNewlineAtEOF)
+	reparsed, err = Parse(last.String())
+	require.NoError(t, err)
+	requireEquivalent(t, &Diff{Files: []*DiffFile{last}}, reparsed)
+
+	require.Equal(t, orig, diff.String())
+}
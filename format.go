@@ -0,0 +1,366 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const noNewlineMarker = `\ No newline at end of file`
+
+// String returns the diff in unified format, as produced by "git diff". The
+// headers are regenerated and the hunk ranges recomputed from the lines in
+// each hunk, so the output stays valid after the diff is modified.
+func (d *Diff) String() string {
+	var b strings.Builder
+	p := &printer{w: &b}
+	d.print(p)
+	return b.String()
+}
+
+// WriteTo writes the diff to w in the same format as String, without building
+// it in memory first. It implements io.WriterTo.
+func (d *Diff) WriteTo(w io.Writer) (int64, error) {
+	p := &printer{w: w}
+	d.print(p)
+	return p.n, p.err
+}
+
+// Patch returns the file as a standalone patch in unified format that "git
+// apply" accepts on its own: the "diff --git" line, the mode, rename, copy
+// and index lines the file has, and its hunks, with the headers regenerated
+// as for Diff.String.
+func (f *DiffFile) Patch() string {
+	var b strings.Builder
+	p := &printer{w: &b}
+	f.print(p)
+	return b.String()
+}
+
+// String returns the file's Patch.
+func (f *DiffFile) String() string {
+	return f.Patch()
+}
+
+// String returns the hunk in unified format, starting with its "@@" line
+// recomputed from the lines it holds. A hunk doesn't know whether it ends its
+// file, so no "\ No newline at end of file" markers are written.
+func (h *DiffHunk) String() string {
+	var b strings.Builder
+	p := &printer{w: &b}
+	h.print(p, false, false)
+	return b.String()
+}
+
+// printer writes to w, keeping count of the bytes written and stopping at the
+// first error.
+type printer struct {
+	w   io.Writer
+	n   int64
+	err error
+
+	// render, if set, is w and is told what each line is, to style it.
+	render *renderer
+}
+
+// mark tells the renderer, if any, that the lines printed next are of kind k.
+// For a line of a hunk, orig and new are its numbers in the original and new
+// file, or 0 if it isn't in that file.
+func (p *printer) mark(k lineKind, orig, new int) {
+	if p.render != nil {
+		p.render.kind, p.render.orig, p.render.new = k, orig, new
+	}
+}
+
+func (p *printer) print(a ...string) {
+	for _, s := range a {
+		if p.err != nil {
+			return
+		}
+		n, err := io.WriteString(p.w, s)
+		p.n += int64(n)
+		p.err = err
+	}
+}
+
+func (d *Diff) print(p *printer) {
+	for _, f := range d.Files {
+		f.print(p)
+	}
+	p.mark(plainLine, 0, 0)
+	p.print(d.Trailing)
+}
+
+func (f *DiffFile) print(p *printer) {
+	origName, newName := f.OrigName, f.NewName
+	if origName == "" {
+		origName = newName
+	}
+	if newName == "" {
+		newName = origName
+	}
+
+	p.mark(fileHeaderLine, 0, 0)
+	if f.isCombined() {
+		p.print("diff --cc ", quoteFileName(newName), "\n")
+	} else {
+		p.print("diff --git ", quoteFileName("a/"+origName), " ", quoteFileName("b/"+newName), "\n")
+	}
+	switch {
+	case f.Mode == NEW && f.NewMode != 0:
+		p.print(newFileModePrefix, formatFileMode(f.NewMode), "\n")
+	case f.Mode == DELETED && f.OldMode != 0:
+		p.print(deletedFileModePrefix, formatFileMode(f.OldMode), "\n")
+	case f.OldMode != 0 && f.NewMode != 0 && f.OldMode != f.NewMode:
+		p.print(oldModePrefix, formatFileMode(f.OldMode), "\n")
+		p.print(newModePrefix, formatFileMode(f.NewMode), "\n")
+	}
+	if f.Mode == RENAMED {
+		if f.SimilarityIndex > 0 {
+			p.print(similarityPrefix, strconv.Itoa(f.SimilarityIndex), "%\n")
+		}
+		p.print(renameFromPrefix, quoteFileName(f.OrigName), "\n")
+		p.print(renameToPrefix, quoteFileName(f.NewName), "\n")
+	}
+	if f.Mode == COPIED {
+		if f.SimilarityIndex > 0 {
+			p.print(similarityPrefix, strconv.Itoa(f.SimilarityIndex), "%\n")
+		}
+		p.print(copyFromPrefix, quoteFileName(f.OrigName), "\n")
+		p.print(copyToPrefix, quoteFileName(f.NewName), "\n")
+	}
+	for _, l := range f.ExtendedHeaders {
+		if !f.isRegeneratedHeader(l) {
+			p.print(l, "\n")
+		}
+	}
+	if index := f.indexLine(); index != "" {
+		p.print(index, "\n")
+	}
+
+	origLabel, newLabel := devNull, devNull
+	if f.Mode != NEW {
+		origLabel = quoteFileName("a/" + origName)
+	}
+	if f.Mode != DELETED {
+		newLabel = quoteFileName("b/" + newName)
+	}
+	if f.IsBinary && len(f.Hunks) == 0 {
+		// The content of a binary patch isn't kept, so only say that
+		// the file changed.
+		p.print(binaryFilesPrefix, origLabel, " and ", newLabel, binaryFilesSuffix, "\n")
+		return
+	}
+	if len(f.Hunks) == 0 {
+		return
+	}
+	p.print("--- ", origLabel, nameTab(origName), "\n")
+	p.print("+++ ", newLabel, nameTab(newName), "\n")
+
+	for i, h := range f.Hunks {
+		var origNoNewline, newNoNewline bool
+		if i == len(f.Hunks)-1 {
+			origNoNewline, newNoNewline = f.OrigNoNewlineAtEOF, f.NewNoNewlineAtEOF
+		}
+		h.print(p, origNoNewline, newNoNewline)
+	}
+}
+
+// isCombined reports whether the file is part of a combined diff.
+func (f *DiffFile) isCombined() bool {
+	return len(f.Hunks) > 0 && len(f.Hunks[0].ParentRanges) > 0
+}
+
+// isRegeneratedHeader reports whether the extended header line l is one
+// print writes from the file's fields rather than as it is.
+func (f *DiffFile) isRegeneratedHeader(l string) bool {
+	if strings.HasPrefix(l, similarityPrefix) {
+		return f.Mode == RENAMED || f.Mode == COPIED
+	}
+	for _, prefix := range []string{
+		indexPrefix,
+		oldModePrefix,
+		newModePrefix,
+		newFileModePrefix,
+		deletedFileModePrefix,
+		renamePrefix,
+		copyFromPrefix,
+		copyToPrefix,
+	} {
+		if strings.HasPrefix(l, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexLine returns the "index" line from the file's header, if any.
+func (f *DiffFile) indexLine() string {
+	for _, l := range strings.Split(f.DiffHeader, "\n") {
+		if strings.HasPrefix(l, indexPrefix) {
+			return l
+		}
+	}
+	return ""
+}
+
+// print writes the hunk, followed by a no-newline marker after the last line
+// of each side flagged as missing one.
+func (h *DiffHunk) print(p *printer, origNoNewline, newNoNewline bool) {
+	lastOrig, lastNew := h.lastLines()
+	h.printHeader(p)
+	orig, new := h.OrigRange.Start, h.NewRange.Start
+	for i, l := range h.WholeRange.Lines {
+		switch l.Mode {
+		case ADDED:
+			p.mark(addedLine, 0, new)
+			new++
+		case REMOVED:
+			p.mark(removedLine, orig, 0)
+			orig++
+		default:
+			p.mark(contextLine, orig, new)
+			orig++
+			new++
+		}
+		if len(h.ParentRanges) > 0 {
+			for _, m := range l.ParentModes {
+				p.print(m.prefix())
+			}
+		} else {
+			p.print(l.Mode.prefix())
+		}
+		p.print(l.Content, "\n")
+		if (origNoNewline && i == lastOrig) || (newNoNewline && i == lastNew) {
+			p.mark(contextLine, 0, 0)
+			p.print(noNewlineMarker, "\n")
+		}
+	}
+}
+
+// lastLines returns the index in WholeRange of the last line of the original
+// file and of the new one, or -1 if the hunk has none.
+func (h *DiffHunk) lastLines() (lastOrig, lastNew int) {
+	lastOrig, lastNew = -1, -1
+	for i, l := range h.WholeRange.Lines {
+		if l.Mode != ADDED {
+			lastOrig = i
+		}
+		if l.Mode != REMOVED {
+			lastNew = i
+		}
+	}
+	return lastOrig, lastNew
+}
+
+func (h *DiffHunk) printHeader(p *printer) {
+	p.mark(hunkHeaderLine, 0, 0)
+	marker := "@@"
+	if len(h.ParentRanges) > 0 {
+		marker = strings.Repeat("@", len(h.ParentRanges)+1)
+		p.print(marker)
+		for _, r := range h.ParentRanges {
+			p.print(" ", formatRange('-', r.Start, len(r.Lines)))
+		}
+	} else {
+		p.print(marker, " ", formatRange('-', h.OrigRange.Start, len(h.OrigRange.Lines)))
+	}
+	p.print(" ", formatRange('+', h.NewRange.Start, len(h.NewRange.Lines)), " ", marker)
+	if h.HunkHeader != "" {
+		p.print(" ", h.HunkHeader)
+	}
+	p.print("\n")
+}
+
+func formatFileMode(mode int) string {
+	return strconv.FormatInt(int64(mode), 8)
+}
+
+// Header formats the range as one side of a hunk header, sign and all, e.g.
+// "-1,3" for an original range or "+0,0" for the new range of a deleted file.
+// Like git, the length is left out when it is 1, as in "+5". String writes
+// the same, but with the length counted from the range's lines.
+func (r *DiffRange) Header(sign byte) string {
+	return formatRange(sign, r.Start, r.Length)
+}
+
+// formatRange formats one side of a hunk header. Like git, the length is
+// left out when it is 1.
+func formatRange(sign byte, start, length int) string {
+	s := string(sign) + strconv.Itoa(start)
+	if length != 1 {
+		s += "," + strconv.Itoa(length)
+	}
+	return s
+}
+
+func (m DiffLineMode) prefix() string {
+	switch m {
+	case ADDED:
+		return "+"
+	case REMOVED:
+		return "-"
+	}
+	return " "
+}
+
+// nameTab returns the tab git puts after a file name containing a space on
+// the "---" and "+++" lines.
+func nameTab(name string) string {
+	if strings.Contains(name, " ") {
+		return "\t"
+	}
+	return ""
+}
+
+// quoteFileName quotes name the way git does if it contains control
+// characters, double quotes, backslashes or non-ASCII bytes.
+func quoteFileName(name string) string {
+	needsQuotes := false
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' {
+			needsQuotes = true
+			break
+		}
+	}
+	if !needsQuotes {
+		return name
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\v':
+			b.WriteString(`\v`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&b, `\%03o`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requireEquivalent asserts that two diffs hold the same changes, ignoring
+// the raw text, "diff" lines and header lines they were parsed from.
+func requireEquivalent(t *testing.T, expected, actual *Diff) {
+	expected, actual = expected.Clone(), actual.Clone()
+	for _, d := range []*Diff{expected, actual} {
+		d.Raw = ""
+		for _, f := range d.Files {
+			f.DiffHeader = ""
+			f.Command = ""
+			f.ExtendedHeaders = nil
+			f.RawSpan = RawSpan{}
+			for _, h := range f.Hunks {
+				h.RawSpan = RawSpan{}
+			}
+		}
+	}
+	require.Equal(t, expected, actual)
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	diff := setup(t)
+	require.Equal(t, diff.Raw, diff.String())
+
+	reparsed, err := Parse(diff.String())
+	require.NoError(t, err)
+	requireEquivalent(t, diff, reparsed)
+}
+
+func TestStringKeepsTrailingBlankLines(t *testing.T) {
+	example := setup(t).Raw
+	for _, input := range []string{
+		example + "\n",
+		example + "\n\n\n",
+		mergeEdit + "\n",
+		"\n\n",
+	} {
+		diff, err := Parse(input)
+		require.NoError(t, err)
+		require.Equal(t, input, diff.String())
+	}
+
+	plain, err := Parse(mergeEdit)
+	require.NoError(t, err)
+	require.Empty(t, plain.Trailing)
+	diff, err := Parse(mergeEdit + "\n\n")
+	require.NoError(t, err)
+	require.Equal(t, "\n\n", diff.Trailing)
+	require.Len(t, diff.Files[0].Hunks[0].WholeRange.Lines, 3)
+	require.False(t, diff.Equal(plain))
+	require.True(t, diff.Equal(plain, IgnoreRaw()))
+}
+
+func TestString(t *testing.T) {
+	diff := setup(t)
+	diff.Files = diff.Files[:4]
+	require.Equal(t, `diff --git a/file1 b/file1
+index 504d2a1..50ccec3 100644
+--- a/file1
++++ b/file1
+@@ -1,4 +1,4 @@
++add a line
+ some
+ lines
+-in
+ file1
+diff --git a/file2 b/file2
+deleted file mode 100644
+index c0dafd8..0000000
+--- a/file2
++++ /dev/null
+@@ -1,4 +0,0 @@
+-other
+-lines
+-in
+-file2
+diff --git a/file3 b/file3
+deleted file mode 100644
+index 576bba8..0000000
+--- a/file3
++++ /dev/null
+@@ -1,4 +0,0 @@
+-still
+-more
+-in
+-file3
+\ No newline at end of file
+diff --git a/file4 b/file4
+new file mode 100644
+index 0000000..57271b1
+--- /dev/null
++++ b/file4
+@@ -0,0 +1 @@
++added new file
+\ No newline at end of file
+`, diff.String())
+}
+
+func TestStringRecomputesHunkHeader(t *testing.T) {
+	diff := setup(t)
+	diff.Files = diff.Files[:1]
+	hunk := diff.Files[0].Hunks[0]
+	hunk.HunkHeader = "func main() {"
+
+	// Drop the removed line "in".
+	hunk.OrigRange.Lines = append(hunk.OrigRange.Lines[:2], hunk.OrigRange.Lines[3:]...)
+	hunk.WholeRange.Lines = append(hunk.WholeRange.Lines[:3], hunk.WholeRange.Lines[4:]...)
+
+	require.Equal(t, `diff --git a/file1 b/file1
+index 504d2a1..50ccec3 100644
+--- a/file1
++++ b/file1
+@@ -1,3 +1,4 @@ func main() {
++add a line
+ some
+ lines
+ file1
+`, diff.String())
+}
+
+func TestStringQuotesNames(t *testing.T) {
+	for _, name := range []string{
+		"with space",
+		"café",
+		`back\slash`,
+		`double"quote`,
+		"tab\tname",
+	} {
+		diff := &Diff{Files: []*DiffFile{{
+			Mode:     MODIFIED,
+			OrigName: name,
+			NewName:  name,
+			Hunks: []*DiffHunk{{
+				OrigRange: DiffRange{Start: 1, Length: 1},
+				NewRange:  DiffRange{Start: 1, Length: 1},
+			}},
+		}}}
+		hunk := diff.Files[0].Hunks[0]
+		orig := &DiffLine{Mode: REMOVED, Number: 1, Content: "a", Position: 1}
+		new := &DiffLine{Mode: ADDED, Number: 1, Content: "b", Position: 2}
+		hunk.OrigRange.Lines = []*DiffLine{orig}
+		hunk.NewRange.Lines = []*DiffLine{new}
+		hunk.WholeRange.Lines = []*DiffLine{orig, new}
+
+		reparsed, err := Parse(diff.String())
+		require.NoError(t, err)
+		requireEquivalent(t, diff, reparsed)
+	}
+
+	require.Equal(t, `"a/caf\303\251"`, quoteFileName("a/café"))
+}
+
+func TestStringRename(t *testing.T) {
+	diff, err := Parse(`diff --git a/old b/new
+similarity index 100%
+rename from old
+rename to new
+`)
+	require.NoError(t, err)
+	require.Equal(t, `diff --git a/old b/new
+similarity index 100%
+rename from old
+rename to new
+`, diff.String())
+}
+
+func TestFileString(t *testing.T) {
+	diff := setup(t)
+	require.Equal(t, `diff --git a/symlink b/symlink
+deleted file mode 120000
+index 03b9162..0000000
+--- a/symlink
++++ /dev/null
+@@ -1 +0,0 @@
+-symlink-destination
+\ No newline at end of file
+`, diff.Files[5].String())
+
+	// A file without hunks only has its header.
+	diff, err := Parse(`diff --git a/old b/new
+similarity index 100%
+rename from old
+rename to new
+`)
+	require.NoError(t, err)
+	require.Equal(t, `diff --git a/old b/new
+similarity index 100%
+rename from old
+rename to new
+`, diff.Files[0].String())
+}
+
+func TestFilePatch(t *testing.T) {
+	second := `diff --git a/q.txt b/q.txt
+old mode 100644
+new mode 100755
+index 587be6b..b77b4eb
+--- a/q.txt
++++ b/q.txt
+@@ -1 +1,2 @@
+ x
++y
+`
+	diff, err := Parse(`diff --git a/p.txt b/p.txt
+index 4cb29ea..ddc897f 100644
+--- a/p.txt
++++ b/p.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+` + second)
+	require.NoError(t, err)
+	require.Equal(t, second, diff.Files[1].Patch())
+	require.Equal(t, second, diff.Files[1].String())
+
+	// The patch stands alone after the diff is filtered.
+	filtered := diff.FilterPaths([]string{"q.txt"}, nil)
+	require.Equal(t, second, filtered.Files[0].Patch())
+	reparsed, err := Parse(filtered.Files[0].Patch())
+	require.NoError(t, err)
+	requireEquivalent(t, filtered, reparsed)
+}
+
+func TestHunkString(t *testing.T) {
+	diff := setup(t)
+	hunk := diff.Files[0].Hunks[0]
+
+	// Drop the added line.
+	hunk.NewRange.Lines = hunk.NewRange.Lines[1:]
+	hunk.WholeRange.Lines = hunk.WholeRange.Lines[1:]
+	require.Equal(t, `@@ -1,4 +1,3 @@
+ some
+ lines
+-in
+ file1
+`, hunk.String())
+
+	// Zero-length ranges keep their start.
+	require.Equal(t, `@@ -0,0 +1 @@
++added new file
+`, diff.Files[3].Hunks[0].String())
+	require.Equal(t, `@@ -1,4 +0,0 @@
+-other
+-lines
+-in
+-file2
+`, diff.Files[1].Hunks[0].String())
+}
+
+// failingWriter accepts limit bytes and then fails.
+type failingWriter struct {
+	limit int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errors.New("writer full")
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriteTo(t *testing.T) {
+	diff := setup(t)
+
+	var b bytes.Buffer
+	n, err := diff.WriteTo(&b)
+	require.NoError(t, err)
+	require.Equal(t, diff.String(), b.String())
+	require.Equal(t, int64(b.Len()), n)
+
+	n, err = diff.WriteTo(&failingWriter{limit: 100})
+	require.EqualError(t, err, "writer full")
+	require.Equal(t, int64(100), n)
+
+	// The diff is written a piece at a time, not built up and written at
+	// once.
+	var w writeCounter
+	_, err = diff.WriteTo(&w)
+	require.NoError(t, err)
+	require.True(t, w.writes > len(diff.Files), "%d writes", w.writes)
+	require.True(t, w.largest < len(diff.String())/len(diff.Files), "largest write %d bytes", w.largest)
+}
+
+var _ io.WriterTo = (*Diff)(nil)
+
+// writeCounter counts the writes made to it and the size of the largest.
+type writeCounter struct {
+	writes  int
+	largest int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	w.largest = max(w.largest, len(p))
+	return len(p), nil
+}
+
+func TestDiffRangeHeader(t *testing.T) {
+	for _, test := range []struct {
+		r        DiffRange
+		sign     byte
+		expected string
+	}{
+		{DiffRange{Start: 1, Length: 3}, '-', "-1,3"},
+		{DiffRange{Start: 12, Length: 7}, '+', "+12,7"},
+		{DiffRange{Start: 1, Length: 1}, '-', "-1"},
+		{DiffRange{Start: 40, Length: 1}, '+', "+40"},
+		{DiffRange{Start: 0, Length: 0}, '-', "-0,0"},
+		{DiffRange{Start: 5, Length: 0}, '+', "+5,0"},
+	} {
+		require.Equal(t, test.expected, test.r.Header(test.sign))
+	}
+
+	// The headers of parsed hunks are as they were written.
+	for _, f := range setup(t).Files {
+		for _, h := range f.Hunks {
+			header := "@@ " + h.OrigRange.Header('-') + " " + h.NewRange.Header('+') + " @@"
+			require.True(t, strings.HasPrefix(h.String(), header+"\n"), header)
+		}
+	}
+}
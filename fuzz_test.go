@@ -0,0 +1,64 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// fuzzSeeds are diffs of each kind Parse reads, added to the fixtures on
+// disk to seed FuzzParse.
+var fuzzSeeds = []string{
+	mergeRename,
+	mergeEdit,
+	"diff --cc f\nindex 1111111,2222222..3333333\n--- a/f\n+++ b/f\n@@@ -1,2 -1,2 +1,2 @@@\n  a\n- b\n -c\n++d\n",
+	"diff --git a/img.png b/img.png\nindex 1111111..2222222 100644\nBinary files a/img.png and b/img.png differ\n",
+	"diff --git a/f b/f\nindex 1111111..2222222 100644\nGIT binary patch\nliteral 5\nMcmZ?wbYf)!0000\n\nliteral 0\nHcmV?d00001\n\n",
+	"diff --git a/f b/f\nold mode 100644\nnew mode 100755\n",
+	"diff --git \"a/with space\" \"b/with space\"\n--- \"a/with space\"\n+++ \"b/with space\"\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n",
+	"--- a/f\t2015-01-01 00:00:00\n+++ b/f\t2015-01-02 00:00:00\n@@ -1,2 +1,2 @@ func\n a\n-b\n+c\n",
+}
+
+// FuzzParse checks that Parse returns an error, rather than panicking, for
+// any input, and that what it parses can be written back out.
+func FuzzParse(f *testing.F) {
+	seeds := append([]string(nil), fuzzSeeds...)
+	for _, path := range []string{"example.diff", "example.patch"} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, string(b))
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+		// Truncate the diff after each line, and mid-line.
+		lines := strings.SplitAfter(seed, "\n")
+		for i := range lines {
+			prefix := strings.Join(lines[:i], "")
+			f.Add(prefix)
+			f.Add(prefix + lines[i][:len(lines[i])/2])
+			// Drop the line.
+			f.Add(prefix + strings.Join(lines[i+1:], ""))
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, opts := range [][]ParseOption{
+			nil,
+			{WithStrictHunkOrder(), WithSlashPaths()},
+		} {
+			diff, err := Parse(input, opts...)
+			if err != nil {
+				continue
+			}
+			_ = diff.String()
+			_ = diff.Changed()
+			_ = diff.Removed()
+			_ = diff.Clone()
+		}
+	})
+}
@@ -1,6 +1,6 @@
 module github.com/waigani/diffparser
 
-go 1.12
+go 1.23
 
 require (
 	github.com/davecgh/go-spew v1.1.1
@@ -0,0 +1,59 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"regexp"
+	"slices"
+)
+
+// Match is a line of the diff matched by Grep or GrepAll.
+type Match struct {
+	File *DiffFile
+	Hunk *DiffHunk
+	Line *DiffLine
+
+	// Pattern is the index of the pattern that matched in the patterns
+	// given to GrepAll, and 0 for Grep.
+	Pattern int
+
+	// Indices locates the leftmost match in the line's Content and its
+	// submatches, as returned by regexp's FindStringSubmatchIndex.
+	Indices []int
+}
+
+// Grep returns the lines of the diff whose content re matches, for lines of
+// the given modes or, if none are given, added lines. Binary files are
+// skipped. Matches are in diff order and unchanged lines are matched once,
+// as the copy in NewRange, so Line.Number is the line in the new file for
+// added and unchanged lines and in the original file for removed ones.
+func (d *Diff) Grep(re *regexp.Regexp, modes ...DiffLineMode) []Match {
+	return d.GrepAll([]*regexp.Regexp{re}, modes...)
+}
+
+// GrepAll is like Grep but matches several patterns in a single pass over
+// the diff. A line matched by more than one pattern gives a match for each,
+// in the order of patterns.
+func (d *Diff) GrepAll(patterns []*regexp.Regexp, modes ...DiffLineMode) []Match {
+	if len(modes) == 0 {
+		modes = []DiffLineMode{ADDED}
+	}
+	var matches []Match
+	for _, f := range d.Files {
+		if f.IsBinary {
+			continue
+		}
+		for h, l := range f.HunkLines() {
+			if !slices.Contains(modes, l.Mode) {
+				continue
+			}
+			for i, re := range patterns {
+				if indices := re.FindStringSubmatchIndex(l.Content); indices != nil {
+					matches = append(matches, Match{File: f, Hunk: h, Line: l, Pattern: i, Indices: indices})
+				}
+			}
+		}
+	}
+	return matches
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const grepDiff = `diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -1,3 +1,4 @@
+ // TODO: old note
+-key := "abc"
++key := os.Getenv("KEY")
++// TODO(jo): tidy up
+ x := 1
+diff --git a/b.go b/b.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/b.go
+@@ -0,0 +1,2 @@
++package b
++var token = "secret" // TODO
+diff --git a/img.png b/img.png
+index 4444444..5555555 100644
+Binary files a/img.png and b/img.png differ
+`
+
+func TestGrep(t *testing.T) {
+	diff, err := Parse(grepDiff)
+	require.NoError(t, err)
+	a, b := diff.Files[0], diff.Files[1]
+
+	type found struct {
+		file    *DiffFile
+		number  int
+		content string
+	}
+	summarise := func(matches []Match) []found {
+		var result []found
+		for _, m := range matches {
+			require.Contains(t, m.File.Hunks, m.Hunk)
+			require.Contains(t, m.Hunk.WholeRange.Lines, m.Line)
+			result = append(result, found{m.File, m.Line.Number, m.Line.Content})
+		}
+		return result
+	}
+
+	todo := regexp.MustCompile(`TODO(?:\((\w+)\))?`)
+	matches := diff.Grep(todo)
+	require.Equal(t, []found{
+		{a, 3, "// TODO(jo): tidy up"},
+		{b, 2, `var token = "secret" // TODO`},
+	}, summarise(matches))
+	require.Equal(t, []int{3, 11, 8, 10}, matches[0].Indices)
+	require.Equal(t, "jo", matches[0].Line.Content[matches[0].Indices[2]:matches[0].Indices[3]])
+	require.Equal(t, []int{24, 28, -1, -1}, matches[1].Indices)
+
+	require.Equal(t, []found{
+		{a, 1, "// TODO: old note"},
+	}, summarise(diff.Grep(todo, UNCHANGED)))
+	require.Equal(t, []found{
+		{a, 2, `key := "abc"`},
+	}, summarise(diff.Grep(regexp.MustCompile(`key`), REMOVED)))
+	require.Equal(t, []found{
+		{a, 2, `key := "abc"`},
+		{a, 2, `key := os.Getenv("KEY")`},
+	}, summarise(diff.Grep(regexp.MustCompile(`key`), ADDED, REMOVED)))
+
+	require.Empty(t, diff.Grep(regexp.MustCompile(`png`)))
+}
+
+func TestGrepAll(t *testing.T) {
+	diff, err := Parse(grepDiff)
+	require.NoError(t, err)
+
+	matches := diff.GrepAll([]*regexp.Regexp{
+		regexp.MustCompile(`"secret"|"abc"`),
+		regexp.MustCompile(`TODO`),
+	}, ADDED, REMOVED)
+	var got [][2]interface{}
+	for _, m := range matches {
+		got = append(got, [2]interface{}{m.Pattern, m.Line.Content})
+	}
+	require.Equal(t, [][2]interface{}{
+		{0, `key := "abc"`},
+		{1, "// TODO(jo): tidy up"},
+		{0, `var token = "secret" // TODO`},
+		{1, `var token = "secret" // TODO`},
+	}, got)
+}
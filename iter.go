@@ -0,0 +1,83 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import "iter"
+
+// The iterators below walk each hunk's WholeRange, so every line of the diff
+// is yielded exactly once and in diff order. Unchanged lines are yielded as
+// the copy held by NewRange, i.e. their Number is the line number in the new
+// file.
+
+// AllLines returns an iterator over every line in the diff, paired with the
+// file it belongs to.
+func (d *Diff) AllLines() iter.Seq2[*DiffFile, *DiffLine] {
+	return func(yield func(*DiffFile, *DiffLine) bool) {
+		for _, f := range d.Files {
+			for _, h := range f.Hunks {
+				for _, l := range h.WholeRange.Lines {
+					if !yield(f, l) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// EachLine calls fn for every line in the diff with the file and hunk it
+// belongs to: the files in order, the hunks of each file in order and the
+// lines of each hunk in WholeRange order, as for AllLines.
+func (d *Diff) EachLine(fn func(f *DiffFile, h *DiffHunk, l *DiffLine)) {
+	for _, f := range d.Files {
+		for h, l := range f.HunkLines() {
+			fn(f, h, l)
+		}
+	}
+}
+
+// Lines returns an iterator over every line in the file.
+func (f *DiffFile) Lines() iter.Seq[*DiffLine] {
+	return func(yield func(*DiffLine) bool) {
+		for _, l := range f.HunkLines() {
+			if !yield(l) {
+				return
+			}
+		}
+	}
+}
+
+// HunkLines returns an iterator over every line in the file, paired with the
+// hunk it belongs to.
+func (f *DiffFile) HunkLines() iter.Seq2[*DiffHunk, *DiffLine] {
+	return func(yield func(*DiffHunk, *DiffLine) bool) {
+		for _, h := range f.Hunks {
+			for _, l := range h.WholeRange.Lines {
+				if !yield(h, l) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// AddedLinesSeq returns an iterator over the lines added to the file.
+func (f *DiffFile) AddedLinesSeq() iter.Seq[*DiffLine] {
+	return f.linesWithMode(ADDED)
+}
+
+// RemovedLinesSeq returns an iterator over the lines removed from the file.
+func (f *DiffFile) RemovedLinesSeq() iter.Seq[*DiffLine] {
+	return f.linesWithMode(REMOVED)
+}
+
+func (f *DiffFile) linesWithMode(mode DiffLineMode) iter.Seq[*DiffLine] {
+	return func(yield func(*DiffLine) bool) {
+		for l := range f.Lines() {
+			if l.Mode == mode && !yield(l) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllLines(t *testing.T) {
+	diff := setup(t)
+
+	var count int
+	names := map[string]int{}
+	for file, line := range diff.AllLines() {
+		require.NotNil(t, line)
+		names[file.OrigName+file.NewName]++
+		count++
+	}
+	require.Equal(t, 19, count)
+	require.Equal(t, 5, names["file1file1"])
+	require.Equal(t, 1, names["symlink"])
+}
+
+func TestEachLine(t *testing.T) {
+	diff := setup(t)
+
+	var count int
+	var positions []int
+	hunks := map[*DiffHunk]int{}
+	diff.EachLine(func(f *DiffFile, h *DiffHunk, l *DiffLine) {
+		require.Contains(t, f.Hunks, h)
+		require.Contains(t, h.WholeRange.Lines, l)
+		hunks[h]++
+		count++
+		if f == diff.Files[0] {
+			positions = append(positions, l.Position)
+		}
+	})
+	require.Equal(t, 19, count)
+	require.Equal(t, diff.TotalLines(), count)
+	require.Len(t, hunks, diff.TotalHunks())
+	require.Equal(t, 5, hunks[diff.Files[0].Hunks[0]])
+	require.Equal(t, []int{1, 2, 3, 4, 5}, positions)
+
+	(&Diff{}).EachLine(func(*DiffFile, *DiffHunk, *DiffLine) {
+		t.Fatal("called for an empty diff")
+	})
+}
+
+func TestFileLines(t *testing.T) {
+	diff := setup(t)
+	file := diff.Files[0]
+
+	var contents []string
+	for line := range file.Lines() {
+		contents = append(contents, line.Content)
+	}
+	require.Equal(t, []string{"add a line", "some", "lines", "in", "file1"}, contents)
+
+	// Unchanged lines carry their new file numbering.
+	var numbers []int
+	for hunk, line := range file.HunkLines() {
+		require.Equal(t, file.Hunks[0], hunk)
+		numbers = append(numbers, line.Number)
+	}
+	require.Equal(t, []int{1, 2, 3, 3, 4}, numbers)
+
+	var added, removed []string
+	for line := range file.AddedLinesSeq() {
+		added = append(added, line.Content)
+	}
+	for line := range file.RemovedLinesSeq() {
+		removed = append(removed, line.Content)
+	}
+	require.Equal(t, []string{"add a line"}, added)
+	require.Equal(t, []string{"in"}, removed)
+}
+
+func TestIteratorsBreakEarly(t *testing.T) {
+	diff := setup(t)
+	goroutines := runtime.NumGoroutine()
+
+	for i := 0; i < 2; i++ {
+		var count int
+		for range diff.AllLines() {
+			count++
+			if count == 3 {
+				break
+			}
+		}
+		require.Equal(t, 3, count)
+
+		count = 0
+		for range diff.Files[1].RemovedLinesSeq() {
+			count++
+			break
+		}
+		require.Equal(t, 1, count)
+	}
+
+	require.Equal(t, goroutines, runtime.NumGoroutine())
+}
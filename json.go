@@ -0,0 +1,100 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import "encoding/json"
+
+// UnmarshalJSON decodes a hunk encoded with encoding/json. JSON has no
+// pointers, so each line the hunk's ranges share is written out in every
+// range holding it; the copies decoded are linked back together, as Parse
+// leaves them, so changing a line in WholeRange changes it in OrigRange or
+// NewRange too. Ranges that don't agree with each other are left unlinked.
+func (h *DiffHunk) UnmarshalJSON(b []byte) error {
+	// plain has DiffHunk's fields but not this method.
+	type plain DiffHunk
+	if err := json.Unmarshal(b, (*plain)(h)); err != nil {
+		return err
+	}
+	if len(h.ParentRanges) > 0 {
+		h.linkCombinedLines()
+	} else {
+		h.linkLines()
+	}
+	return nil
+}
+
+// linkLines makes WholeRange hold the lines of NewRange, and the removed
+// lines of OrigRange, in their place.
+func (h *DiffHunk) linkLines() {
+	origLines, newLines := h.OrigRange.Lines, h.NewRange.Lines
+	lines := make([]*DiffLine, len(h.WholeRange.Lines))
+	for i, l := range h.WholeRange.Lines {
+		side := &newLines
+		if l.Mode == REMOVED {
+			side = &origLines
+			// Unchanged lines have a copy of their own in OrigRange.
+			for len(origLines) > 0 && origLines[0].Mode == UNCHANGED {
+				origLines = origLines[1:]
+			}
+		}
+		if len(*side) == 0 {
+			return
+		}
+		lines[i], *side = (*side)[0], (*side)[1:]
+	}
+	h.WholeRange.Lines = lines
+}
+
+// linkCombinedLines links the lines of a combined hunk as Parse does: each
+// line of WholeRange is the one in NewRange or, for lines removed from the
+// result, in the first parent's range it is removed from, and OrigRange holds
+// the first parent's lines.
+func (h *DiffHunk) linkCombinedLines() {
+	var next int
+	cursors := make([]int, len(h.ParentRanges))
+	lines := make([]*DiffLine, len(h.WholeRange.Lines))
+	for i, l := range h.WholeRange.Lines {
+		if l.Mode != REMOVED {
+			if next >= len(h.NewRange.Lines) {
+				return
+			}
+			lines[i] = h.NewRange.Lines[next]
+			next++
+		}
+		for p, pm := range l.ParentModes {
+			if pm == ADDED || (pm == UNCHANGED && l.Mode == REMOVED) {
+				continue
+			}
+			if p >= len(cursors) || cursors[p] >= len(h.ParentRanges[p].Lines) {
+				return
+			}
+			if lines[i] == nil {
+				lines[i] = h.ParentRanges[p].Lines[cursors[p]]
+			}
+			cursors[p]++
+		}
+		if lines[i] == nil {
+			return
+		}
+	}
+	h.WholeRange.Lines = lines
+	h.OrigRange.Lines = h.ParentRanges[0].Lines
+}
+
+// JSONOptions configures Diff.JSON.
+type JSONOptions struct {
+	// OmitRaw leaves out the diff's raw text, which repeats everything
+	// else.
+	OmitRaw bool
+}
+
+// JSON encodes the diff as JSON, as json.Marshal does, with the fields left
+// out that opts asks for. d is left as it was.
+func (d *Diff) JSON(opts JSONOptions) ([]byte, error) {
+	c := *d
+	if opts.OmitRaw {
+		c.Raw = ""
+	}
+	return json.Marshal(&c)
+}
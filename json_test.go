@@ -0,0 +1,143 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	input := "diff --git a/f b/f\nindex 1111111..2222222 100644\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@ func\n a\n-b\n+c\n"
+	diff, err := Parse(input)
+	require.NoError(t, err)
+	diff.PullID = 7
+
+	b, err := json.Marshal(diff)
+	require.NoError(t, err)
+	line := `{"mode":"unchanged","number":1,"content":"a","position":1}`
+	removed := `{"mode":"removed","number":2,"content":"b","position":2}`
+	added := `{"mode":"added","number":2,"content":"c","position":3}`
+	require.JSONEq(t, `{
+		"files": [{
+			"diffHeader": "diff --git a/f b/f\nindex 1111111..2222222 100644\n--- a/f\n+++ b/f",
+			"mode": "modified",
+			"origName": "f",
+			"newName": "f",
+			"command": "diff --git a/f b/f",
+			"extendedHeaders": ["index 1111111..2222222 100644"],
+			"hunks": [{
+				"hunkHeader": "func",
+				"origRange": {"start": 1, "length": 2, "lines": [`+line+`, `+removed+`]},
+				"newRange": {"start": 1, "length": 2, "lines": [`+line+`, `+added+`]},
+				"wholeRange": {"start": 0, "length": 0, "lines": [`+line+`, `+removed+`, `+added+`]},
+				"rawSpan": {"start": 65, "end": 95}
+			}],
+			"isBinary": false,
+			"similarityIndex": 0,
+			"oldMode": 0,
+			"newMode": 0,
+			"origNoNewlineAtEOF": false,
+			"newNoNewlineAtEOF": false,
+			"rawSpan": {"start": 0, "end": 95}
+		}],
+		"raw": `+string(mustMarshal(t, input))+`
+	}`, string(b))
+
+	b, err = diff.JSON(JSONOptions{OmitRaw: true})
+	require.NoError(t, err)
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(b, &fields))
+	require.Contains(t, fields, "files")
+	require.NotContains(t, fields, "raw")
+	require.Equal(t, input, diff.Raw)
+}
+
+func TestMarshalModes(t *testing.T) {
+	for mode, expected := range map[FileMode]string{
+		DELETED:  `"deleted"`,
+		MODIFIED: `"modified"`,
+		NEW:      `"new"`,
+		RENAMED:  `"renamed"`,
+		COPIED:   `"copied"`,
+	} {
+		require.Equal(t, expected, string(mustMarshal(t, mode)))
+	}
+	for mode, expected := range map[DiffLineMode]string{
+		ADDED:     `"added"`,
+		REMOVED:   `"removed"`,
+		UNCHANGED: `"unchanged"`,
+	} {
+		require.Equal(t, expected, string(mustMarshal(t, mode)))
+	}
+
+	_, err := json.Marshal(FileMode(9))
+	require.Error(t, err)
+	_, err = json.Marshal(DiffLineMode(9))
+	require.Error(t, err)
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	combined := `diff --cc f.txt
+index 0e42946,9982675..b4a7def
+--- a/f.txt
++++ b/f.txt
+@@@ -1,3 -1,3 +1,4 @@@
+  line1
+- from main
+ -from b
+++merged
+  line3
+++extra
+`
+	diff := setup(t)
+	parsed, err := Parse(combined)
+	require.NoError(t, err)
+	diff.Files = append(diff.Files, parsed.Files...)
+	diff.Raw += combined
+
+	b, err := json.Marshal(diff)
+	require.NoError(t, err)
+	var decoded Diff
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, diff, &decoded)
+	require.Equal(t, diff.Changed(), decoded.Changed())
+	require.Equal(t, diff.String(), decoded.String())
+
+	// The ranges share lines as they do after parsing.
+	for _, f := range decoded.Files {
+		for _, h := range f.Hunks {
+			for _, l := range h.WholeRange.Lines {
+				r := &h.NewRange
+				if l.Mode == REMOVED {
+					r = &h.OrigRange
+					if len(h.ParentRanges) > 0 && !slices.Contains(r.Lines, l) {
+						r = &h.ParentRanges[1]
+					}
+				}
+				require.True(t, slices.Contains(r.Lines, l), "%s: %q", f.NewName, l.Content)
+			}
+		}
+	}
+	line := decoded.Files[0].Hunks[0].WholeRange.Lines[0]
+	line.Content = "changed"
+	require.Equal(t, "changed", decoded.Files[0].Hunks[0].NewRange.Lines[0].Content)
+}
+
+func TestUnmarshalInvalidModes(t *testing.T) {
+	var f DiffFile
+	require.EqualError(t, json.Unmarshal([]byte(`{"mode":"moved"}`), &f), `unknown file mode "moved"`)
+	var l DiffLine
+	require.EqualError(t, json.Unmarshal([]byte(`{"mode":"gone"}`), &l), `unknown line mode "gone"`)
+	require.Error(t, json.Unmarshal([]byte(`{"mode":1}`), &l))
+}
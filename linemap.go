@@ -0,0 +1,122 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import "sort"
+
+// LineMapper maps line numbers between the original and new versions of a
+// file. It is built once from a DiffFile and answers each query with a
+// binary search over the hunks, so it suits remapping many lines.
+//
+// Lines outside the hunks map by the number of lines added and removed
+// before them. Inside a hunk, unchanged lines map to each other while removed
+// and added lines have no counterpart. Nothing maps for new or deleted files.
+type LineMapper struct {
+	unmapped bool
+	hunks    []hunkMap
+}
+
+// hunkMap holds the lines a hunk covers on each side.
+type hunkMap struct {
+	orig, new hunkSide
+}
+
+// hunkSide holds the first line a hunk covers on one side, and for each line
+// it covers the number of the matching line on the other side, or 0 if there
+// is none.
+type hunkSide struct {
+	first   int
+	mapping []int
+}
+
+// NewLineMapper builds a LineMapper for f.
+func NewLineMapper(f *DiffFile) *LineMapper {
+	m := &LineMapper{unmapped: f.Mode == NEW || f.Mode == DELETED}
+	if m.unmapped {
+		return m
+	}
+	for _, h := range f.Hunks {
+		hm := hunkMap{
+			orig: hunkSide{first: h.origIndex() + 1, mapping: make([]int, len(h.OrigRange.Lines))},
+			new:  hunkSide{first: h.NewRange.Start + 1, mapping: make([]int, len(h.NewRange.Lines))},
+		}
+		if len(h.NewRange.Lines) > 0 {
+			hm.new.first = h.NewRange.Lines[0].Number
+		}
+
+		// The unchanged lines appear in the same order on both sides.
+		var origKept, newKept []*DiffLine
+		for _, l := range h.OrigRange.Lines {
+			if l.Mode == UNCHANGED {
+				origKept = append(origKept, l)
+			}
+		}
+		for _, l := range h.NewRange.Lines {
+			if l.Mode == UNCHANGED {
+				newKept = append(newKept, l)
+			}
+		}
+		for i := 0; i < len(origKept) && i < len(newKept); i++ {
+			o, n := origKept[i].Number, newKept[i].Number
+			if j := o - hm.orig.first; j >= 0 && j < len(hm.orig.mapping) {
+				hm.orig.mapping[j] = n
+			}
+			if j := n - hm.new.first; j >= 0 && j < len(hm.new.mapping) {
+				hm.new.mapping[j] = o
+			}
+		}
+		m.hunks = append(m.hunks, hm)
+	}
+	return m
+}
+
+// NewForOrig returns the number in the new file of line n of the original
+// file, and false if the line was removed.
+func (m *LineMapper) NewForOrig(n int) (int, bool) {
+	return m.lookup(n, func(h *hunkMap) (*hunkSide, *hunkSide) { return &h.orig, &h.new })
+}
+
+// OrigForNew returns the number in the original file of line n of the new
+// file, and false if the line was added.
+func (m *LineMapper) OrigForNew(n int) (int, bool) {
+	return m.lookup(n, func(h *hunkMap) (*hunkSide, *hunkSide) { return &h.new, &h.orig })
+}
+
+// lookup maps n from one side of the file to the other. sides returns a
+// hunk's side that n is on, then the other side.
+func (m *LineMapper) lookup(n int, sides func(*hunkMap) (*hunkSide, *hunkSide)) (int, bool) {
+	if m.unmapped || n < 1 {
+		return 0, false
+	}
+
+	// Find the last hunk starting at or before n.
+	i := sort.Search(len(m.hunks), func(i int) bool {
+		from, _ := sides(&m.hunks[i])
+		return from.first > n
+	}) - 1
+	if i < 0 {
+		return n, true
+	}
+	from, to := sides(&m.hunks[i])
+	if j := n - from.first; j < len(from.mapping) {
+		if mapped := from.mapping[j]; mapped > 0 {
+			return mapped, true
+		}
+		return 0, false
+	}
+
+	// After the hunk, lines are shifted by the difference in the number of
+	// lines it covers on each side.
+	return n + (to.first + len(to.mapping)) - (from.first + len(from.mapping)), true
+}
+
+// MapAll maps each of lines, numbers in the original file, to its number in
+// the new file, with 0 for lines that were removed.
+func (m *LineMapper) MapAll(lines []int) []int {
+	mapped := make([]int, len(lines))
+	for i, n := range lines {
+		mapped[i], _ = m.NewForOrig(n)
+	}
+	return mapped
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineMapper(t *testing.T) {
+	diff, err := Parse(`diff --git a/f b/f
+index 1111111..2222222 100644
+--- a/f
++++ b/f
+@@ -3,3 +3,4 @@
+ 3
+-4
++four
++4.5
+ 5
+@@ -10,2 +10,0 @@
+-10
+-11
+@@ -20,0 +20,1 @@
++new
+`)
+	require.NoError(t, err)
+	m := NewLineMapper(diff.Files[0])
+
+	for _, test := range []struct {
+		orig, new int
+		ok        bool
+	}{
+		{orig: 1, new: 1, ok: true},
+		{orig: 3, new: 3, ok: true},
+		{orig: 4, ok: false},
+		{orig: 5, new: 6, ok: true},
+		{orig: 9, new: 10, ok: true},
+		{orig: 10, ok: false},
+		{orig: 11, ok: false},
+		{orig: 12, new: 11, ok: true},
+		{orig: 20, new: 19, ok: true},
+		{orig: 21, new: 21, ok: true},
+		{orig: 100, new: 100, ok: true},
+	} {
+		n, ok := m.NewForOrig(test.orig)
+		require.Equal(t, test.ok, ok, "orig %d", test.orig)
+		require.Equal(t, test.new, n, "orig %d", test.orig)
+	}
+
+	for _, test := range []struct {
+		new, orig int
+		ok        bool
+	}{
+		{new: 2, orig: 2, ok: true},
+		{new: 4, ok: false},
+		{new: 5, ok: false},
+		{new: 6, orig: 5, ok: true},
+		{new: 11, orig: 12, ok: true},
+		{new: 20, ok: false},
+		{new: 21, orig: 21, ok: true},
+	} {
+		n, ok := m.OrigForNew(test.new)
+		require.Equal(t, test.ok, ok, "new %d", test.new)
+		require.Equal(t, test.orig, n, "new %d", test.new)
+	}
+
+	require.Equal(t, []int{1, 0, 6, 0, 11}, m.MapAll([]int{1, 4, 5, 10, 12}))
+}
+
+func TestLineMapperNewAndDeletedFiles(t *testing.T) {
+	diff := setup(t)
+	for _, f := range diff.Files[1:5] {
+		m := NewLineMapper(f)
+		_, ok := m.NewForOrig(1)
+		require.False(t, ok, f.NewName)
+		_, ok = m.OrigForNew(1)
+		require.False(t, ok, f.NewName)
+	}
+}
+
+// TestLineMapperMatchesCompare checks the mapper against the line matching
+// Compare works from, over every line of both files.
+func TestLineMapperMatchesCompare(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		l := make([]string, r.Intn(30))
+		for i := range l {
+			l[i] = string(rune('a' + r.Intn(4)))
+		}
+		return l
+	}
+	for i := 0; i < 200; i++ {
+		a, b := lines(), lines()
+		if len(a) == 0 || len(b) == 0 {
+			continue
+		}
+		origToNew := make(map[int]int)
+		newToOrig := make(map[int]int)
+		for _, e := range myers(len(a), len(b), func(i, j int) bool { return a[i] == b[j] }) {
+			if e.op == editEqual {
+				origToNew[e.orig+1] = e.new + 1
+				newToOrig[e.new+1] = e.orig + 1
+			}
+		}
+
+		for _, context := range []int{0, 1, 3} {
+			diff := Compare(strings.Join(a, "\n")+"\n", strings.Join(b, "\n")+"\n", "f", WithContext(context))
+			m := NewLineMapper(diff.Files[0])
+			for n := 1; n <= len(a); n++ {
+				mapped, ok := m.NewForOrig(n)
+				expected, expectedOK := origToNew[n]
+				require.Equal(t, expectedOK, ok)
+				require.Equal(t, expected, mapped)
+			}
+			for n := 1; n <= len(b); n++ {
+				mapped, ok := m.OrigForNew(n)
+				expected, expectedOK := newToOrig[n]
+				require.Equal(t, expectedOK, ok)
+				require.Equal(t, expected, mapped)
+			}
+		}
+	}
+}
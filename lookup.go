@@ -0,0 +1,79 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+// The lookups below read Files afresh on every call rather than caching an
+// index, as Files and the names in it are exported and may change at any
+// time, so each takes time linear in the number of files. Callers making many
+// lookups should take a map from FilesByNewName or FilesByOrigName once and
+// use it while they leave Files alone.
+
+// File returns the file whose NewName or OrigName is path, so a renamed file
+// is found by either name. Names must match exactly. A file whose new name
+// matches is preferred over one whose original name does, and otherwise the
+// first match in Files is returned. An empty path matches nothing, although
+// new files have no OrigName and deleted files no NewName.
+func (d *Diff) File(path string) (*DiffFile, bool) {
+	if path == "" {
+		return nil, false
+	}
+	for _, f := range d.Files {
+		if f.NewName == path {
+			return f, true
+		}
+	}
+	for _, f := range d.Files {
+		if f.OrigName == path {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// FilesByNewName returns a new map of NewName to file, built from Files on
+// each call, so it reflects any change made to Files before the call but none
+// made after. Deleted files have no new name and are left out. If several
+// files share a name, the first in Files is kept, as File keeps it.
+func (d *Diff) FilesByNewName() map[string]*DiffFile {
+	files := make(map[string]*DiffFile, len(d.Files))
+	for _, f := range d.Files {
+		if _, ok := files[f.NewName]; !ok && f.NewName != "" {
+			files[f.NewName] = f
+		}
+	}
+	return files
+}
+
+// FilesByOrigName returns a new map of OrigName to file, built from Files on
+// each call as FilesByNewName's is. New files have no original name and are
+// left out. If several files share a name, the first in Files is kept.
+func (d *Diff) FilesByOrigName() map[string]*DiffFile {
+	files := make(map[string]*DiffFile, len(d.Files))
+	for _, f := range d.Files {
+		if _, ok := files[f.OrigName]; !ok && f.OrigName != "" {
+			files[f.OrigName] = f
+		}
+	}
+	return files
+}
+
+// Paths returns the path of each file in the diff, in order and without
+// duplicates: its NewName or, for a deleted file, its OrigName. A renamed
+// file is listed by its new name only.
+func (d *Diff) Paths() []string {
+	paths := make([]string, 0, len(d.Files))
+	seen := make(map[string]bool, len(d.Files))
+	for _, f := range d.Files {
+		path := f.NewName
+		if path == "" {
+			path = f.OrigName
+		}
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	return paths
+}
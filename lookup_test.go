@@ -0,0 +1,95 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile(t *testing.T) {
+	diff := setup(t)
+
+	for path, expected := range map[string]*DiffFile{
+		"file1":   diff.Files[0],
+		"file2":   diff.Files[1],
+		"file4":   diff.Files[3],
+		"missing": nil,
+		"":        nil,
+	} {
+		f, ok := diff.File(path)
+		require.Equal(t, expected != nil, ok, path)
+		require.Equal(t, expected, f, path)
+	}
+
+	renamed := &DiffFile{Mode: RENAMED, OrigName: "old", NewName: "new"}
+	created := &DiffFile{Mode: NEW, NewName: "old"}
+	diff = &Diff{Files: []*DiffFile{renamed, created}}
+	f, _ := diff.File("new")
+	require.Equal(t, renamed, f)
+	// The new file named "old" is preferred over the rename from it.
+	f, _ = diff.File("old")
+	require.Equal(t, created, f)
+}
+
+func TestFileFollowsChanges(t *testing.T) {
+	diff := setup(t)
+	diff.Files[0].NewName = "moved"
+	f, ok := diff.File("moved")
+	require.True(t, ok)
+	require.Equal(t, diff.Files[0], f)
+
+	diff.Files = diff.Files[:1]
+	_, ok = diff.File("file4")
+	require.False(t, ok)
+}
+
+func TestFilesByName(t *testing.T) {
+	diff := setup(t)
+
+	byNew := diff.FilesByNewName()
+	require.Len(t, byNew, 3)
+	require.Equal(t, diff.Files[0], byNew["file1"])
+	require.Equal(t, diff.Files[3], byNew["file4"])
+	require.Equal(t, diff.Files[4], byNew["newname"])
+
+	byOrig := diff.FilesByOrigName()
+	require.Len(t, byOrig, 4)
+	require.Equal(t, diff.Files[0], byOrig["file1"])
+	require.Equal(t, diff.Files[1], byOrig["file2"])
+	require.Equal(t, diff.Files[2], byOrig["file3"])
+	require.Equal(t, diff.Files[5], byOrig["symlink"])
+
+	// The maps are built afresh, so they follow changes to Files made
+	// between calls, while one already returned is left as it was.
+	diff.Files[0].NewName = "moved"
+	diff.Files = diff.Files[:2]
+	require.Equal(t, map[string]*DiffFile{"moved": diff.Files[0]}, diff.FilesByNewName())
+	require.Len(t, diff.FilesByOrigName(), 2)
+	require.Len(t, byNew, 3)
+
+	// The first of several files sharing a name is kept, as File keeps it.
+	first := &DiffFile{Mode: MODIFIED, OrigName: "f", NewName: "f"}
+	second := &DiffFile{Mode: MODIFIED, OrigName: "f", NewName: "f"}
+	diff = &Diff{Files: []*DiffFile{first, second}}
+	require.Same(t, first, diff.FilesByNewName()["f"])
+	require.Same(t, first, diff.FilesByOrigName()["f"])
+	f, _ := diff.File("f")
+	require.Same(t, first, f)
+}
+
+func TestPaths(t *testing.T) {
+	diff := setup(t)
+	require.Equal(t, []string{"file1", "file2", "file3", "file4", "newname", "symlink"}, diff.Paths())
+
+	diff = &Diff{Files: []*DiffFile{
+		{Mode: RENAMED, OrigName: "old", NewName: "new"},
+		{Mode: MODIFIED, OrigName: "new", NewName: "new"},
+		{Mode: DELETED, OrigName: "gone"},
+		{Mode: NEW, NewName: "old"},
+	}}
+	require.Equal(t, []string{"new", "gone", "old"}, diff.Paths())
+	require.Empty(t, (&Diff{}).Paths())
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrMergeConflict is returned, wrapped, by Merge when diffs can't be merged
+// under the policy given.
+var ErrMergeConflict = errors.New("merge conflict")
+
+// MergePolicy says what Merge does with a file whose path is in more than
+// one of the diffs.
+type MergePolicy int
+
+const (
+	// MergeFail makes Merge return an error.
+	MergeFail MergePolicy = iota
+
+	// MergeKeepBoth keeps each of the files, so the merged diff has more
+	// than one file for the path.
+	MergeKeepBoth
+
+	// MergeHunks combines the files into one holding the hunks of both,
+	// as if the diffs were taken against the same original file. The
+	// hunks are ordered by where they start in the original file and the
+	// new line numbers of each are shifted by the lines added and removed
+	// by the other's hunks before it. Merge fails if the hunks overlap,
+	// context lines included, or if the files can't be combined: created,
+	// deleted and binary files, and files renamed differently, can't.
+	MergeHunks
+)
+
+// Merge returns a diff holding the files of each of diffs in turn. A path is
+// a file's OrigName and NewName; when one is in more than one of the diffs,
+// policy says what is done. Files are shared with the diffs, which are left
+// as they were, except those combined by MergeHunks, and Raw is regenerated.
+func Merge(policy MergePolicy, diffs ...*Diff) (*Diff, error) {
+	type entry struct {
+		file, diff int
+	}
+	merged := &Diff{}
+	seen := make(map[string]entry)
+	for i, d := range diffs {
+		if d == nil {
+			continue
+		}
+	files:
+		for _, f := range d.Files {
+			for _, name := range []string{f.OrigName, f.NewName} {
+				prev, ok := seen[name]
+				if name == "" || !ok || prev.diff == i {
+					continue
+				}
+				switch policy {
+				case MergeKeepBoth:
+					continue
+				case MergeHunks:
+					combined, err := mergeFiles(merged.Files[prev.file], f)
+					if err != nil {
+						return nil, err
+					}
+					merged.Files[prev.file] = combined
+					for _, n := range []string{combined.OrigName, combined.NewName} {
+						seen[n] = entry{prev.file, i}
+					}
+					continue files
+				default:
+					return nil, fmt.Errorf("%w: %s is in more than one diff", ErrMergeConflict, name)
+				}
+			}
+			for _, name := range []string{f.OrigName, f.NewName} {
+				seen[name] = entry{len(merged.Files), i}
+			}
+			merged.Files = append(merged.Files, f)
+		}
+	}
+	merged.Raw = merged.String()
+	return merged, nil
+}
+
+// mergeFiles combines the hunks of a and b into a new file, as described for
+// MergeHunks.
+func mergeFiles(a, b *DiffFile) (*DiffFile, error) {
+	name := a.NewName
+	if name == "" {
+		name = a.OrigName
+	}
+	for _, f := range []*DiffFile{a, b} {
+		if f.Mode == NEW || f.Mode == DELETED || f.IsBinary {
+			return nil, fmt.Errorf("%w: %s is created, deleted or binary", ErrMergeConflict, name)
+		}
+	}
+	// A file can be renamed or copied by both diffs only if it is the same
+	// rename or copy.
+	moved := func(f *DiffFile) bool { return f.Mode == RENAMED || f.Mode == COPIED }
+	if moved(a) && moved(b) && (a.Mode != b.Mode || a.OrigName != b.OrigName || a.NewName != b.NewName) {
+		return nil, fmt.Errorf("%w: %s is renamed differently", ErrMergeConflict, name)
+	}
+
+	c := a.Clone()
+	// The header and "diff" line describe a alone.
+	c.DiffHeader = ""
+	c.Command = ""
+	if moved(b) {
+		c.Mode, c.OrigName, c.NewName, c.SimilarityIndex = b.Mode, b.OrigName, b.NewName, b.SimilarityIndex
+	}
+	if c.OldMode == 0 && c.NewMode == 0 {
+		c.OldMode, c.NewMode = b.OldMode, b.NewMode
+	}
+
+	// Remember the hunks' files and the lines each file's earlier hunks
+	// added, to shift the new line numbers by the difference.
+	type source struct {
+		file   *DiffFile
+		before int
+	}
+	sources := make(map[*DiffHunk]source)
+	for _, f := range []*DiffFile{c, b} {
+		var net int
+		for _, h := range f.Hunks {
+			if f == b {
+				h = h.Clone()
+				c.Hunks = append(c.Hunks, h)
+			}
+			sources[h] = source{f, net}
+			net += h.NewRange.Length - h.OrigRange.Length
+		}
+	}
+	sort.SliceStable(c.Hunks, func(i, j int) bool {
+		return c.Hunks[i].origIndex() < c.Hunks[j].origIndex()
+	})
+
+	var net int
+	for i, h := range c.Hunks {
+		if i > 0 {
+			prev := c.Hunks[i-1]
+			if start := h.origIndex(); start == prev.origIndex() || start < prev.origIndex()+prev.OrigRange.Length {
+				return nil, fmt.Errorf("%w: %s has overlapping hunks at line %d", ErrMergeConflict, name, start+1)
+			}
+		}
+		if shift := net - sources[h].before; shift != 0 {
+			h.NewRange.Start += shift
+			for _, l := range h.NewRange.Lines {
+				l.Number += shift
+			}
+		}
+		net += h.NewRange.Length - h.OrigRange.Length
+	}
+
+	// The no-newline flags belong to the last hunk.
+	if len(c.Hunks) > 0 && sources[c.Hunks[len(c.Hunks)-1]].file == b {
+		c.OrigNoNewlineAtEOF, c.NewNoNewlineAtEOF = b.OrigNoNewlineAtEOF, b.NewNoNewlineAtEOF
+	}
+	c.renumberPositions()
+	return c, nil
+}
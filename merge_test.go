@@ -0,0 +1,164 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const mergeRename = `diff --git a/old.go b/new.go
+similarity index 100%
+rename from old.go
+rename to new.go
+`
+
+const mergeEdit = `diff --git a/new.go b/new.go
+index 1111111..2222222 100644
+--- a/new.go
++++ b/new.go
+@@ -2 +2,2 @@
+-b
++B
++B2
+`
+
+const mergeOther = `diff --git a/other.go b/other.go
+index 3333333..4444444 100644
+--- a/other.go
++++ b/other.go
+@@ -1 +1 @@
+-x
++y
+`
+
+func parseAll(t *testing.T, diffs ...string) []*Diff {
+	var parsed []*Diff
+	for _, d := range diffs {
+		diff, err := Parse(d)
+		require.NoError(t, err)
+		parsed = append(parsed, diff)
+	}
+	return parsed
+}
+
+func TestMerge(t *testing.T) {
+	diffs := parseAll(t, mergeOther, mergeEdit)
+	merged, err := Merge(MergeFail, diffs...)
+	require.NoError(t, err)
+	require.Len(t, merged.Files, 2)
+	require.Equal(t, "other.go", merged.Files[0].NewName)
+	require.Equal(t, "new.go", merged.Files[1].NewName)
+	require.Equal(t, mergeOther+mergeEdit, merged.Raw)
+}
+
+func TestMergeRenameAndEdit(t *testing.T) {
+	diffs := parseAll(t, mergeRename, mergeEdit)
+
+	_, err := Merge(MergeFail, diffs...)
+	require.True(t, errors.Is(err, ErrMergeConflict))
+	require.EqualError(t, err, "merge conflict: new.go is in more than one diff")
+
+	merged, err := Merge(MergeKeepBoth, diffs...)
+	require.NoError(t, err)
+	require.Len(t, merged.Files, 2)
+	require.Equal(t, mergeRename+mergeEdit, merged.Raw)
+
+	merged, err = Merge(MergeHunks, diffs...)
+	require.NoError(t, err)
+	require.Len(t, merged.Files, 1)
+	f := merged.Files[0]
+	require.Equal(t, RENAMED, f.Mode)
+	require.Equal(t, "old.go", f.OrigName)
+	require.Equal(t, "new.go", f.NewName)
+	require.Len(t, f.Hunks, 1)
+	require.Equal(t, `diff --git a/old.go b/new.go
+similarity index 100%
+rename from old.go
+rename to new.go
+--- a/old.go
++++ b/new.go
+@@ -2 +2,2 @@
+-b
++B
++B2
+`, merged.Raw)
+
+	// The inputs are left as they were.
+	require.Empty(t, diffs[0].Files[0].Hunks)
+}
+
+func TestMergeHunks(t *testing.T) {
+	first := `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1,2 +1,3 @@
+ a
++a2
+ b
+@@ -20 +21 @@
+-t
++T
+\ No newline at end of file
+`
+	second := `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -10,2 +10 @@
+ j
+-k
+`
+	merged, err := Merge(MergeHunks, parseAll(t, first, second)...)
+	require.NoError(t, err)
+	require.Len(t, merged.Files, 1)
+	require.Equal(t, `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1,2 +1,3 @@
+ a
++a2
+ b
+@@ -10,2 +11 @@
+ j
+-k
+@@ -20 +20 @@
+-t
++T
+\ No newline at end of file
+`, merged.Raw)
+
+	f := merged.Files[0]
+	require.Equal(t, 11, f.Hunks[1].NewRange.Lines[0].Number)
+	require.Equal(t, 20, f.Hunks[2].NewRange.Lines[0].Number)
+	require.True(t, f.NewNoNewlineAtEOF)
+	line, ok := f.LineForPosition(6)
+	require.True(t, ok)
+	require.Equal(t, "k", line.Content)
+
+	reparsed, err := Parse(merged.Raw)
+	require.NoError(t, err)
+	requireEquivalent(t, merged, reparsed)
+}
+
+func TestMergeHunksConflicts(t *testing.T) {
+	for _, test := range []struct {
+		diffs   []string
+		message string
+	}{{
+		diffs:   []string{mergeEdit, mergeEdit},
+		message: "merge conflict: new.go has overlapping hunks at line 2",
+	}, {
+		diffs:   []string{mergeEdit, "diff --git a/new.go b/new.go\ndeleted file mode 100644\n"},
+		message: "merge conflict: new.go is created, deleted or binary",
+	}, {
+		diffs:   []string{mergeRename, "diff --git a/old.go b/other.go\nrename from old.go\nrename to other.go\n"},
+		message: "merge conflict: new.go is renamed differently",
+	}} {
+		_, err := Merge(MergeHunks, parseAll(t, test.diffs...)...)
+		require.True(t, errors.Is(err, ErrMergeConflict), err)
+		require.EqualError(t, err, test.message)
+	}
+}
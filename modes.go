@@ -0,0 +1,108 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fileModeNames and lineModeNames are the names of the FileMode and
+// DiffLineMode values in text and JSON, which don't change if the constants
+// are reordered.
+var (
+	fileModeNames = map[FileMode]string{
+		DELETED:  "deleted",
+		MODIFIED: "modified",
+		NEW:      "new",
+		RENAMED:  "renamed",
+		COPIED:   "copied",
+	}
+	lineModeNames = map[DiffLineMode]string{
+		ADDED:     "added",
+		REMOVED:   "removed",
+		UNCHANGED: "unchanged",
+	}
+)
+
+// String returns the name of the mode: "deleted", "modified", "new",
+// "renamed" or "copied". Other values are written as e.g. "FileMode(7)".
+func (m FileMode) String() string {
+	if name, ok := fileModeNames[m]; ok {
+		return name
+	}
+	return "FileMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// ParseFileMode returns the mode named s, as String writes it. Case is
+// ignored.
+func ParseFileMode(s string) (FileMode, error) {
+	for m, name := range fileModeNames {
+		if strings.EqualFold(s, name) {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown file mode %q", s)
+}
+
+// MarshalText implements encoding.TextMarshaler, and so JSON encoding, using
+// the mode's name. It fails for values that have none.
+func (m FileMode) MarshalText() ([]byte, error) {
+	name, ok := fileModeNames[m]
+	if !ok {
+		return nil, fmt.Errorf("unknown file mode %d", int(m))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, as ParseFileMode.
+func (m *FileMode) UnmarshalText(text []byte) error {
+	mode, err := ParseFileMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+// String returns the name of the mode: "added", "removed" or "unchanged".
+// Other values are written as e.g. "DiffLineMode(7)".
+func (m DiffLineMode) String() string {
+	if name, ok := lineModeNames[m]; ok {
+		return name
+	}
+	return "DiffLineMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// ParseDiffLineMode returns the mode named s, as String writes it. Case is
+// ignored.
+func ParseDiffLineMode(s string) (DiffLineMode, error) {
+	for m, name := range lineModeNames {
+		if strings.EqualFold(s, name) {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown line mode %q", s)
+}
+
+// MarshalText implements encoding.TextMarshaler, and so JSON encoding, using
+// the mode's name. It fails for values that have none.
+func (m DiffLineMode) MarshalText() ([]byte, error) {
+	name, ok := lineModeNames[m]
+	if !ok {
+		return nil, fmt.Errorf("unknown line mode %d", int(m))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, as ParseDiffLineMode.
+func (m *DiffLineMode) UnmarshalText(text []byte) error {
+	mode, err := ParseDiffLineMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
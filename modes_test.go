@@ -0,0 +1,71 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileModeText(t *testing.T) {
+	for mode, name := range map[FileMode]string{
+		DELETED:  "deleted",
+		MODIFIED: "modified",
+		NEW:      "new",
+		RENAMED:  "renamed",
+		COPIED:   "copied",
+	} {
+		require.Equal(t, name, mode.String())
+		text, err := mode.MarshalText()
+		require.NoError(t, err)
+		require.Equal(t, name, string(text))
+
+		var parsed FileMode
+		require.NoError(t, parsed.UnmarshalText(text))
+		require.Equal(t, mode, parsed)
+	}
+
+	mode, err := ParseFileMode("Deleted")
+	require.NoError(t, err)
+	require.Equal(t, DELETED, mode)
+	_, err = ParseFileMode("moved")
+	require.EqualError(t, err, `unknown file mode "moved"`)
+
+	require.Equal(t, "FileMode(7)", FileMode(7).String())
+	_, err = FileMode(7).MarshalText()
+	require.EqualError(t, err, "unknown file mode 7")
+	require.Equal(t, "{new file1}", fmt.Sprint(struct {
+		Mode FileMode
+		Name string
+	}{NEW, "file1"}))
+}
+
+func TestDiffLineModeText(t *testing.T) {
+	for mode, name := range map[DiffLineMode]string{
+		ADDED:     "added",
+		REMOVED:   "removed",
+		UNCHANGED: "unchanged",
+	} {
+		require.Equal(t, name, mode.String())
+		text, err := mode.MarshalText()
+		require.NoError(t, err)
+		require.Equal(t, name, string(text))
+
+		var parsed DiffLineMode
+		require.NoError(t, parsed.UnmarshalText(text))
+		require.Equal(t, mode, parsed)
+	}
+
+	mode, err := ParseDiffLineMode("ADDED")
+	require.NoError(t, err)
+	require.Equal(t, ADDED, mode)
+	_, err = ParseDiffLineMode("gone")
+	require.EqualError(t, err, `unknown line mode "gone"`)
+
+	require.Equal(t, "DiffLineMode(7)", DiffLineMode(7).String())
+	_, err = DiffLineMode(7).MarshalText()
+	require.EqualError(t, err, "unknown line mode 7")
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import "sort"
+
+// minMoveLines is the number of lines a run must have to count as moved, so
+// that short, common lines such as closing braces aren't reported.
+const minMoveLines = 3
+
+// Move is a run of lines removed from one file and added, identical, to
+// another.
+type Move struct {
+	// From is the file the lines were removed from.
+	From *DiffFile
+	// To is the file the lines were added to.
+	To *DiffFile
+	// Removed holds the removed lines, in order.
+	Removed []*DiffLine
+	// Added holds the added lines, in the same order.
+	Added []*DiffLine
+}
+
+// lineRun is a run of consecutive lines with the same mode in a hunk.
+type lineRun struct {
+	file  *DiffFile
+	lines []*DiffLine
+}
+
+// DetectMoves finds blocks of lines that were removed from one file and
+// added to another unchanged, as when code is relocated in a refactor. Only
+// runs of at least 3 consecutive lines count, and each line is part of at
+// most one move, the longest runs being matched first. The moves are ordered
+// by where their removed lines appear in the diff.
+//
+// Every removed line is compared against every added line, so this can be
+// slow for large diffs and is not done by Parse.
+func (d *Diff) DetectMoves() []Move {
+	removed := d.lineRuns(REMOVED)
+	added := d.lineRuns(ADDED)
+
+	// Index the added lines by content to find where matches start.
+	type location struct{ run, index int }
+	byContent := make(map[string][]location)
+	for i, r := range added {
+		for j, l := range r.lines {
+			byContent[l.Content] = append(byContent[l.Content], location{i, j})
+		}
+	}
+
+	type candidate struct {
+		removedRun, removedIndex int
+		addedRun, addedIndex     int
+		length                   int
+	}
+	var candidates []candidate
+	for i, r := range removed {
+		for j, l := range r.lines {
+			for _, loc := range byContent[l.Content] {
+				a := added[loc.run]
+				if a.file == r.file {
+					continue
+				}
+				// Only consider matches that can't be extended backwards.
+				if j > 0 && loc.index > 0 && r.lines[j-1].Content == a.lines[loc.index-1].Content {
+					continue
+				}
+				n := 0
+				for j+n < len(r.lines) && loc.index+n < len(a.lines) && r.lines[j+n].Content == a.lines[loc.index+n].Content {
+					n++
+				}
+				if n >= minMoveLines {
+					candidates = append(candidates, candidate{i, j, loc.run, loc.index, n})
+				}
+			}
+		}
+	}
+
+	// Claim the longest matches first, trimming later ones to the lines
+	// still free.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].length > candidates[j].length
+	})
+	used := make(map[*DiffLine]bool)
+	var moves []Move
+	order := make(map[*DiffLine]int)
+	for _, r := range removed {
+		for _, l := range r.lines {
+			order[l] = len(order)
+		}
+	}
+	for _, c := range candidates {
+		from, to := removed[c.removedRun], added[c.addedRun]
+		for start := 0; start < c.length; {
+			end := start
+			for end < c.length && !used[from.lines[c.removedIndex+end]] && !used[to.lines[c.addedIndex+end]] {
+				end++
+			}
+			if end-start >= minMoveLines {
+				m := Move{
+					From:    from.file,
+					To:      to.file,
+					Removed: from.lines[c.removedIndex+start : c.removedIndex+end],
+					Added:   to.lines[c.addedIndex+start : c.addedIndex+end],
+				}
+				for k := range m.Removed {
+					used[m.Removed[k]] = true
+					used[m.Added[k]] = true
+				}
+				moves = append(moves, m)
+			}
+			start = end + 1
+		}
+	}
+
+	sort.SliceStable(moves, func(i, j int) bool {
+		return order[moves[i].Removed[0]] < order[moves[j].Removed[0]]
+	})
+	return moves
+}
+
+// lineRuns returns the runs of consecutive lines with the given mode in each
+// hunk of the diff.
+func (d *Diff) lineRuns(mode DiffLineMode) []lineRun {
+	var runs []lineRun
+	for _, f := range d.Files {
+		for _, h := range f.Hunks {
+			var run []*DiffLine
+			for _, l := range h.WholeRange.Lines {
+				if l.Mode == mode {
+					run = append(run, l)
+					continue
+				}
+				if len(run) > 0 {
+					runs = append(runs, lineRun{f, run})
+					run = nil
+				}
+			}
+			if len(run) > 0 {
+				runs = append(runs, lineRun{f, run})
+			}
+		}
+	}
+	return runs
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectMoves(t *testing.T) {
+	diff, err := Parse(`diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -1,9 +1,4 @@
+ package a
+-
+-func helper() int {
+-	return 1
+-}
+-}
+-x := 1
+ 
+ func A() {}
+diff --git a/b.go b/b.go
+index 3333333..4444444 100644
+--- a/b.go
++++ b/b.go
+@@ -1,3 +1,9 @@
+ package b
++
++func helper() int {
++	return 1
++}
++x := 1
+ 
+ func B() {}
+`)
+	require.NoError(t, err)
+	a, b := diff.Files[0], diff.Files[1]
+
+	moves := diff.DetectMoves()
+	require.Len(t, moves, 1)
+	m := moves[0]
+	require.Equal(t, a, m.From)
+	require.Equal(t, b, m.To)
+	require.Len(t, m.Removed, 4)
+	require.Len(t, m.Added, 4)
+	for i := range m.Removed {
+		require.Equal(t, REMOVED, m.Removed[i].Mode)
+		require.Equal(t, ADDED, m.Added[i].Mode)
+		require.Equal(t, m.Removed[i].Content, m.Added[i].Content)
+	}
+	require.Equal(t, "", m.Removed[0].Content)
+	require.Equal(t, 2, m.Removed[0].Number)
+	require.Equal(t, 2, m.Added[0].Number)
+	require.Equal(t, "}", m.Removed[3].Content)
+}
+
+func TestDetectMovesIgnoresShortRunsAndSameFile(t *testing.T) {
+	diff, err := Parse(`diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -1,4 +1,4 @@
+-one
+-two
+-three
++one
++two
++three
+ four
+diff --git a/b.go b/b.go
+index 3333333..4444444 100644
+--- a/b.go
++++ b/b.go
+@@ -1,2 +1,3 @@
+-}
++}
++}
+ end
+diff --git a/c.go b/c.go
+index 5555555..6666666 100644
+--- a/c.go
++++ b/c.go
+@@ -1,1 +1,2 @@
++}
++}
+ end
+`)
+	require.NoError(t, err)
+	require.Empty(t, diff.DetectMoves())
+}
+
+func TestDetectMovesClaimsLinesOnce(t *testing.T) {
+	diff, err := Parse(`diff --git a/a.go b/a.go
+deleted file mode 100644
+index 1111111..0000000
+--- a/a.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-one
+-two
+-three
+diff --git a/b.go b/b.go
+new file mode 100644
+index 0000000..2222222
+--- /dev/null
++++ b/b.go
+@@ -0,0 +1,3 @@
++one
++two
++three
+diff --git a/c.go b/c.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/c.go
+@@ -0,0 +1,3 @@
++one
++two
++three
+`)
+	require.NoError(t, err)
+	moves := diff.DetectMoves()
+	require.Len(t, moves, 1)
+	require.Equal(t, diff.Files[1], moves[0].To)
+	require.Len(t, moves[0].Removed, 3)
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ParseOption configures how Parse reads a diff.
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	stripQuotes     bool
+	detectIndent    bool
+	hunkHeaderReg   *regexp.Regexp
+	hunkHeaderFunc  func(string) string
+	maxFiles        int
+	maxBytes        int
+	maxLines        int
+	stripPrefix     string
+	slashPaths      bool
+	strictHunkOrder bool
+	recount         bool
+	lineOffsets     bool
+}
+
+// ErrDiffTooLarge is returned, wrapped, by Parse when a diff exceeds a limit
+// set with WithMaxFiles, WithMaxBytes or WithMaxLines.
+var ErrDiffTooLarge = errors.New("diff too large")
+
+// WithQuoteStripping removes email-style quoting from the start of each line
+// before it is parsed, so a patch quoted in a reply ("> +foo", "> > +foo")
+// can be parsed directly. Each level of quoting is a ">" optionally followed
+// by a space. Lines that are not quoted are left as they are.
+func WithQuoteStripping() ParseOption {
+	return func(o *parseOptions) {
+		o.stripQuotes = true
+	}
+}
+
+// WithDetectedIndent removes a common indent from the start of each line
+// before it is parsed, for diffs embedded in markdown or YAML blocks. The
+// indent is the whitespace before the first "diff", "---" or "@@" line. It is
+// removed from every line that starts with it, and lines that don't are
+// ignored as text surrounding the diff.
+func WithDetectedIndent() ParseOption {
+	return func(o *parseOptions) {
+		o.detectIndent = true
+	}
+}
+
+// WithStripPrefix removes prefix from the start of each line before it is
+// parsed, for diffs embedded in text with a fixed prefix such as "> " or four
+// spaces. The prefix is removed once from lines that start with it; other
+// lines are left as they are, except that a line holding only the prefix
+// less its trailing whitespace, as editors leave blank quoted lines, becomes
+// empty.
+func WithStripPrefix(prefix string) ParseOption {
+	return func(o *parseOptions) {
+		o.stripPrefix = prefix
+	}
+}
+
+// stripPrefix removes prefix from line, as described by WithStripPrefix.
+func stripPrefix(line, prefix string) string {
+	if strings.HasPrefix(line, prefix) {
+		return line[len(prefix):]
+	}
+	if line == strings.TrimRight(prefix, " \t") {
+		return ""
+	}
+	return line
+}
+
+// detectIndent returns the whitespace before the first line that starts a
+// diff.
+func detectIndent(lines []string) string {
+	for _, l := range lines {
+		trimmed := strings.TrimLeft(l, " \t")
+		for _, start := range []string{"diff ", "--- ", "@@ "} {
+			if strings.HasPrefix(trimmed, start) {
+				return l[:len(l)-len(trimmed)]
+			}
+		}
+	}
+	return ""
+}
+
+// WithHunkHeaderRegexp replaces the pattern used to parse hunk headers, for
+// tools whose headers differ from git's. The pattern must have the same five
+// capture groups as the default: the original start and length, the new start
+// and length, and the section heading. Parse returns an error if it doesn't.
+// Lines that start with "@@ " begin a new hunk, as do lines that match the
+// pattern outside a hunk or after all the lines its header counts, so that
+// the lines of a hunk aren't mistaken for headers.
+func WithHunkHeaderRegexp(re *regexp.Regexp) ParseOption {
+	return func(o *parseOptions) {
+		o.hunkHeaderReg = re
+	}
+}
+
+// WithHunkHeaderFunc passes the text following each hunk's "@@" line
+// through fn, storing the result in HunkHeader. It lets tools that put
+// metadata there rather than a code section rewrite or clear it while
+// parsing. fn is called for every hunk, with "" if there is no text.
+func WithHunkHeaderFunc(fn func(raw string) string) ParseOption {
+	return func(o *parseOptions) {
+		o.hunkHeaderFunc = fn
+	}
+}
+
+// stripQuotes removes all levels of email-style quoting from line. Only the
+// single space following each ">" is removed, so the space that marks an
+// unchanged line survives.
+func stripQuotes(line string) string {
+	for strings.HasPrefix(line, ">") {
+		line = strings.TrimPrefix(line[1:], " ")
+	}
+	return line
+}
+
+// WithMaxFiles limits the number of files a diff may hold. Parse stops with
+// ErrDiffTooLarge at the first file over the limit. Zero means no limit.
+func WithMaxFiles(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.maxFiles = n
+	}
+}
+
+// WithMaxBytes limits the size of the diff in bytes. Parse returns
+// ErrDiffTooLarge for larger diffs before parsing any of it, ParseFile before
+// reading the file and ParseReader as soon as it has read a byte too many.
+// Zero means no limit.
+func WithMaxBytes(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.maxBytes = n
+	}
+}
+
+// WithMaxLines limits the number of lines in the diff. Parse returns
+// ErrDiffTooLarge for longer diffs before parsing any of it, and ParseReader
+// as soon as it has read one line too many. Zero means no limit.
+func WithMaxLines(n int) ParseOption {
+	return func(o *parseOptions) {
+		o.maxLines = n
+	}
+}
+
+// WithSlashPaths replaces the backslashes in file names with forward slashes,
+// for diffs made on Windows by tools that write paths such as
+// "C:\Users\me\file.txt". Without it, names are kept as they are written,
+// backslashes and drive letters included.
+func WithSlashPaths() ParseOption {
+	return func(o *parseOptions) {
+		o.slashPaths = true
+	}
+}
+
+// WithStrictHunkOrder makes Parse check that each hunk of a file starts after
+// the one before it ends, in both the original and the new file, and fail
+// with ErrHunkOutOfOrder otherwise. Git never writes such hunks, so they mark
+// a corrupted or crafted diff, whose line numbers can't be trusted.
+func WithStrictHunkOrder() ParseOption {
+	return func(o *parseOptions) {
+		o.strictHunkOrder = true
+	}
+}
+
+// WithRecount makes Parse take the length of each hunk's ranges from the
+// lines in the hunk rather than from its "@@" line, as "git apply --recount"
+// does, for patches edited by hand without updating their headers. Lines are
+// numbered from each range's start as usual, and lines beyond the lengths the
+// header gives are kept rather than failing with ErrLineOutsideHunk.
+func WithRecount() ParseOption {
+	return func(o *parseOptions) {
+		o.recount = true
+	}
+}
+
+// WithLineOffsets makes Parse record where each line is in its input, in the
+// line's Offset and Length, for tools that map lines back to the raw diff.
+// The offsets are into the input as given, before options such as
+// WithStripPrefix remove anything from the lines. They are left zero
+// otherwise.
+func WithLineOffsets() ParseOption {
+	return func(o *parseOptions) {
+		o.lineOffsets = true
+	}
+}
@@ -0,0 +1,449 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// quote prefixes every line of s with prefix, as an email client would.
+func quote(s, prefix string) string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix) + "\n"
+}
+
+func TestWithQuoteStripping(t *testing.T) {
+	byt, err := ioutil.ReadFile("example.diff")
+	require.NoError(t, err)
+	expected := setup(t)
+
+	for _, prefix := range []string{"> ", "> > ", ">> "} {
+		quoted := quote(string(byt), prefix)
+
+		// Quoting is only stripped when asked for.
+		diff, err := Parse(quoted)
+		require.NoError(t, err)
+		require.Empty(t, diff.Files)
+
+		diff, err = Parse(quoted, WithQuoteStripping())
+		require.NoError(t, err)
+		require.Equal(t, quoted, diff.Raw)
+		requireEquivalent(t, expected, diff)
+	}
+}
+
+func TestStripQuotes(t *testing.T) {
+	for line, expected := range map[string]string{
+		"> +foo":    "+foo",
+		">  foo":    " foo",
+		"> > -foo":  "-foo",
+		">> @@":     "@@",
+		">":         "",
+		"> +> foo":  "+> foo",
+		"+> foo":    "+> foo",
+		"unquoted ": "unquoted ",
+	} {
+		require.Equal(t, expected, stripQuotes(line), line)
+	}
+}
+
+func TestWithHunkHeaderRegexp(t *testing.T) {
+	input := `diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -1,2 +1,2 @@ ## section
+-a
++b
+ c
+`
+	re := regexp.MustCompile(`@@ -(\d+),?(\d+)? \+(\d+),?(\d+)? @@ ## (.+)`)
+	diff, err := Parse(input, WithHunkHeaderRegexp(re))
+	require.NoError(t, err)
+	hunk := diff.Files[0].Hunks[0]
+	require.Equal(t, "section", hunk.HunkHeader)
+	require.Equal(t, 2, hunk.NewRange.Length)
+
+	// Without the option the marker is part of the heading.
+	diff, err = Parse(input)
+	require.NoError(t, err)
+	require.Equal(t, "## section", diff.Files[0].Hunks[0].HunkHeader)
+
+	// Headers that don't start with "@@ " can be matched too.
+	diff, err = Parse(strings.Replace(input, "@@ -1,2 +1,2 @@ ## section", "## -1,2 +1,2 ##", 1),
+		WithHunkHeaderRegexp(regexp.MustCompile(`^## -(\d+),(\d+) \+(\d+),(\d+) ##()$`)))
+	require.NoError(t, err)
+	require.Equal(t, 2, diff.Files[0].Hunks[0].OrigRange.Length)
+	require.Len(t, diff.Files[0].Hunks[0].WholeRange.Lines, 3)
+
+	// Lines of a hunk aren't taken for headers, even if they match.
+	loose := regexp.MustCompile(`## -(\d+),(\d+) \+(\d+),(\d+) ##()`)
+	diff, err = Parse(`diff --git a/file b/file
+--- a/file
++++ b/file
+## -1,3 +1,3 ##
+-a
++// ## -9,1 +9,1 ##
+ ## -5,5 +5,5 ## in a comment
+ c
+## -10,1 +10,1 ##
+-d
++e
+`, WithHunkHeaderRegexp(loose))
+	require.NoError(t, err)
+	require.Len(t, diff.Files[0].Hunks, 2)
+	require.Len(t, diff.Files[0].Hunks[0].WholeRange.Lines, 4)
+	require.Equal(t, 10, diff.Files[0].Hunks[1].OrigRange.Start)
+
+	_, err = Parse(input, WithHunkHeaderRegexp(regexp.MustCompile(`@@ -(\d+) \+(\d+) @@`)))
+	require.EqualError(t, err, "hunk header regexp must have 5 capture groups, has 2")
+}
+
+func TestWithDetectedIndent(t *testing.T) {
+	byt, err := ioutil.ReadFile("example.diff")
+	require.NoError(t, err)
+	expected := setup(t)
+
+	for _, indent := range []string{"    ", "\t", "  \t"} {
+		indented := "Some text before the diff:\n\n" + quote(string(byt), indent) + "\nand after.\n"
+
+		diff, err := Parse(indented, WithDetectedIndent())
+		require.NoError(t, err)
+		requireEquivalent(t, expected, diff)
+	}
+
+	// Nothing is removed from a diff that isn't indented.
+	diff, err := Parse(string(byt), WithDetectedIndent())
+	require.NoError(t, err)
+	require.Equal(t, expected, diff)
+}
+
+func TestWithHunkHeaderFunc(t *testing.T) {
+	var raw []string
+	diff, err := Parse(`diff --git a/file b/file
+--- a/file
++++ b/file
+@@ -1,4 +1,4 @@ SPDX: foo
+-a
++b
+@@ -10 +10 @@ func main() {
+-c
++d
+@@ -20 +20 @@
+-e
++f
+`, WithHunkHeaderFunc(func(header string) string {
+		raw = append(raw, header)
+		if strings.HasPrefix(header, "SPDX: ") {
+			return ""
+		}
+		return strings.ToUpper(header)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"SPDX: foo", "func main() {", ""}, raw)
+
+	hunks := diff.Files[0].Hunks
+	require.Equal(t, "", hunks[0].HunkHeader)
+	require.Equal(t, "FUNC MAIN() {", hunks[1].HunkHeader)
+	require.Equal(t, "", hunks[2].HunkHeader)
+}
+
+func TestWithMaxFiles(t *testing.T) {
+	b, err := ioutil.ReadFile("example.diff")
+	require.NoError(t, err)
+
+	diff, err := Parse(string(b), WithMaxFiles(6))
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 6)
+
+	_, err = Parse(string(b), WithMaxFiles(5))
+	require.True(t, errors.Is(err, ErrDiffTooLarge))
+	require.EqualError(t, err, "diff too large: more than 5 files")
+}
+
+func TestWithMaxBytes(t *testing.T) {
+	b, err := ioutil.ReadFile("example.diff")
+	require.NoError(t, err)
+
+	_, err = Parse(string(b), WithMaxBytes(len(b)))
+	require.NoError(t, err)
+
+	_, err = Parse(string(b), WithMaxBytes(len(b)-1))
+	require.True(t, errors.Is(err, ErrDiffTooLarge))
+
+	_, err = ParseFile("example.diff", WithMaxBytes(len(b)-1))
+	require.True(t, errors.Is(err, ErrDiffTooLarge))
+	require.EqualError(t, err, fmt.Sprintf("example.diff: diff too large: %d bytes, limit is %d", len(b), len(b)-1))
+}
+
+func TestWithMaxLines(t *testing.T) {
+	b, err := ioutil.ReadFile("example.diff")
+	require.NoError(t, err)
+	lines := strings.Count(string(b), "\n")
+
+	_, err = Parse(string(b), WithMaxLines(lines))
+	require.NoError(t, err)
+
+	_, err = Parse(string(b), WithMaxLines(lines-1))
+	require.True(t, errors.Is(err, ErrDiffTooLarge))
+	require.EqualError(t, err, fmt.Sprintf("diff too large: %d lines, limit is %d", lines, lines-1))
+
+	// A last line without a newline counts.
+	_, err = Parse(mergeOther+"x", WithMaxLines(7))
+	require.True(t, errors.Is(err, ErrDiffTooLarge))
+}
+
+// endlessDiff is a reader of a diff that never ends.
+type endlessDiff struct {
+	header string
+}
+
+func (r *endlessDiff) Read(p []byte) (int, error) {
+	if r.header != "" {
+		n := copy(p, r.header)
+		r.header = r.header[n:]
+		return n, nil
+	}
+	for i := range p {
+		p[i] = "+x\n"[i%3]
+	}
+	return len(p), nil
+}
+
+func TestParseReaderLimits(t *testing.T) {
+	b, err := ioutil.ReadFile("example.diff")
+	require.NoError(t, err)
+	diff, err := ParseReader(bytes.NewReader(b), WithMaxBytes(len(b)), WithMaxLines(strings.Count(string(b), "\n")))
+	require.NoError(t, err)
+	requireEquivalent(t, setup(t), diff)
+
+	header := "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -0,0 +1,999999999 @@\n"
+	_, err = ParseReader(&endlessDiff{header: header}, WithMaxBytes(1<<20))
+	require.True(t, errors.Is(err, ErrDiffTooLarge))
+	require.EqualError(t, err, "reading diff: diff too large: more than 1048576 bytes")
+
+	_, err = ParseReader(&endlessDiff{header: header}, WithMaxLines(1000))
+	require.True(t, errors.Is(err, ErrDiffTooLarge))
+	require.EqualError(t, err, "reading diff: diff too large: more than 1000 lines")
+
+	// A hunk claiming more lines than the diff holds is read as it is.
+	diff, err = ParseReader(strings.NewReader(header+"+x\n"), WithMaxLines(10))
+	require.NoError(t, err)
+	require.Len(t, diff.Files[0].Hunks[0].NewRange.Lines, 1)
+}
+
+func TestWithStripPrefix(t *testing.T) {
+	byt, err := ioutil.ReadFile("example.diff")
+	require.NoError(t, err)
+	expected := setup(t)
+
+	for _, prefix := range []string{"> ", "    ", "\t"} {
+		diff, err := Parse(quote(string(byt), prefix), WithStripPrefix(prefix))
+		require.NoError(t, err)
+		requireEquivalent(t, expected, diff)
+	}
+
+	// Only one copy of the prefix is removed, so content that starts like
+	// it survives.
+	diff, err := Parse(`> diff --git a/f b/f
+> --- a/f
+> +++ b/f
+> @@ -1,3 +1,3 @@
+>  > quoted
+> -> old
+> +> new
+>
+`, WithStripPrefix("> "))
+	require.NoError(t, err)
+	var contents []string
+	for l := range diff.Files[0].Lines() {
+		contents = append(contents, l.Content)
+	}
+	require.Equal(t, []string{"> quoted", "> old", "> new"}, contents)
+}
+
+func TestStripPrefix(t *testing.T) {
+	for _, test := range []struct {
+		line, prefix, expected string
+	}{
+		{"> +a", "> ", "+a"},
+		{">  a", "> ", " a"},
+		{">", "> ", ""},
+		{"+a", "> ", "+a"},
+		{"    -a", "    ", "-a"},
+		{"  -a", "    ", "  -a"},
+	} {
+		require.Equal(t, test.expected, stripPrefix(test.line, test.prefix), test.line)
+	}
+}
+
+func TestWithSlashPaths(t *testing.T) {
+	input := `diff --git a/C:\Users\me\old.txt b/C:\Users\me\new.txt
+similarity index 90%
+rename from "C:\\Users\\me\\old.txt"
+rename to "C:\\Users\\me\\new.txt"
+`
+	diff, err := Parse(input)
+	require.NoError(t, err)
+	require.Equal(t, `C:\Users\me\old.txt`, diff.Files[0].OrigName)
+	require.Equal(t, `C:\Users\me\new.txt`, diff.Files[0].NewName)
+
+	diff, err = Parse(input, WithSlashPaths())
+	require.NoError(t, err)
+	require.Equal(t, "C:/Users/me/old.txt", diff.Files[0].OrigName)
+	require.Equal(t, "C:/Users/me/new.txt", diff.Files[0].NewName)
+}
+
+func TestWithStrictHunkOrder(t *testing.T) {
+	header := "diff --git a/f b/f\n--- a/f\n+++ b/f\n"
+	for _, test := range []struct {
+		hunks string
+		err   string
+	}{{
+		hunks: "@@ -1,2 +1,2 @@\n a\n-b\n+c\n@@ -3 +3 @@\n-d\n+e\n",
+	}, {
+		// Adding lines after those the previous hunk ends with.
+		hunks: "@@ -1 +1 @@\n-a\n+b\n@@ -1,0 +2 @@\n+c\n",
+	}, {
+		hunks: "@@ -10 +10 @@\n-a\n+b\n@@ -2 +2 @@\n-c\n+d\n",
+		err:   "f: line 7: hunk out of order: @@ -2 +2 @@",
+	}, {
+		hunks: "@@ -1,3 +1,3 @@\n a\n-b\n+c\n d\n@@ -3 +3 @@\n-d\n+e\n",
+		err:   "f: line 9: hunk out of order: @@ -3 +3 @@",
+	}, {
+		// Only the new ranges overlap.
+		hunks: "@@ -1 +1,3 @@\n-a\n+b\n+c\n+d\n@@ -5 +3 @@\n-e\n+f\n",
+		err:   "f: line 9: hunk out of order: @@ -5 +3 @@",
+	}, {
+		hunks: "@@@ -5 -5 +5 @@@\n--a\n++b\n@@@ -1 -1 +1 @@@\n--c\n++d\n",
+		err:   "f: line 7: hunk out of order: @@@ -1 -1 +1 @@@",
+	}} {
+		input := header + test.hunks
+		_, err := Parse(input)
+		require.NoError(t, err, test.hunks)
+
+		_, err = Parse(input, WithStrictHunkOrder())
+		if test.err == "" {
+			require.NoError(t, err, test.hunks)
+			continue
+		}
+		require.True(t, errors.Is(err, ErrHunkOutOfOrder), test.hunks)
+		require.EqualError(t, err, test.err)
+	}
+}
+
+func TestWithRecount(t *testing.T) {
+	// The first hunk's header undercounts both sides, the second's
+	// overcounts them.
+	input := "diff --git a/f b/f\n--- a/f\n+++ b/f\n" +
+		"@@ -1,2 +1,2 @@\n a\n-b\n+c\n+d\n e\n" +
+		"@@ -10,5 +11,5 @@\n-f\n+g\n"
+
+	diff, err := Parse(input, WithRecount())
+	require.NoError(t, err)
+	require.NoError(t, diff.Validate())
+	hunks := diff.Files[0].Hunks
+	require.Equal(t, 3, hunks[0].OrigRange.Length)
+	require.Equal(t, 4, hunks[0].NewRange.Length)
+	require.Equal(t, []int{1, 2, 3}, lineNumbers(hunks[0].OrigRange.Lines))
+	require.Equal(t, []int{1, 2, 3, 4}, lineNumbers(hunks[0].NewRange.Lines))
+	require.Equal(t, 1, hunks[1].OrigRange.Length)
+	require.Equal(t, 1, hunks[1].NewRange.Length)
+	require.True(t, strings.HasPrefix(hunks[1].String(), "@@ -10 +11 @@\n"), hunks[1].String())
+
+	// Without it, the header counts are kept.
+	diff, err = Parse(input)
+	require.NoError(t, err)
+	require.Equal(t, 2, diff.Files[0].Hunks[0].OrigRange.Length)
+	require.Error(t, diff.Validate())
+
+	// Lines past a range of length 0 are counted rather than rejected.
+	input = "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -5,2 +5,0 @@\n-a\n+b\n"
+	_, err = Parse(input)
+	require.True(t, errors.Is(err, ErrLineOutsideHunk))
+	diff, err = Parse(input, WithRecount())
+	require.NoError(t, err)
+	require.Equal(t, 1, diff.Files[0].Hunks[0].NewRange.Length)
+
+	// Hunks are checked for order with their recounted lengths.
+	input = "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,5 +1,5 @@\n-a\n+b\n@@ -3 +3 @@\n-c\n+d\n"
+	_, err = Parse(input, WithStrictHunkOrder())
+	require.True(t, errors.Is(err, ErrHunkOutOfOrder))
+	_, err = Parse(input, WithStrictHunkOrder(), WithRecount())
+	require.NoError(t, err)
+
+	// Combined diffs are recounted per parent.
+	diff, err = Parse("diff --cc f\n--- a/f\n+++ b/f\n@@@ -1,4 -1,4 +1,4 @@@\n--a\n++b\n", WithRecount())
+	require.NoError(t, err)
+	h := diff.Files[0].Hunks[0]
+	require.Equal(t, 1, h.ParentRanges[0].Length)
+	require.Equal(t, 1, h.ParentRanges[1].Length)
+	require.Equal(t, 1, h.NewRange.Length)
+}
+
+func TestWithLineOffsets(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		input string
+		opts  []ParseOption
+		lines []string
+	}{{
+		name:  "plain",
+		input: "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file",
+		lines: []string{" a", "-b", "+c"},
+	}, {
+		name:  "crlf",
+		input: "diff --git a/f b/f\r\n--- a/f\r\n+++ b/f\r\n@@ -1,2 +1,2 @@\r\n a\r\n-b\r\n+c\r\n",
+		lines: []string{" a\r", "-b\r", "+c\r"},
+	}, {
+		name:  "no trailing newline",
+		input: "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c",
+		lines: []string{" a", "-b", "+c"},
+	}, {
+		name:  "stripped prefix",
+		input: "> diff --git a/f b/f\n> --- a/f\n> +++ b/f\n> @@ -1,2 +1,2 @@\n>  a\n> -b\n> +c\n",
+		opts:  []ParseOption{WithStripPrefix("> ")},
+		lines: []string{" a", "-b", "+c"},
+	}, {
+		name:  "quotes",
+		input: "> > diff --git a/f b/f\n> > --- a/f\n>> +++ b/f\n> > @@ -1,2 +1,2 @@\n> >  a\n>> -b\n> > +c\n",
+		opts:  []ParseOption{WithQuoteStripping()},
+		lines: []string{" a", "-b", "+c"},
+	}, {
+		name:  "indent",
+		input: "Try this:\n\n    diff --git a/f b/f\n    --- a/f\n    +++ b/f\n    @@ -1,2 +1,2 @@\n     a\n    -b\n    +c\n",
+		opts:  []ParseOption{WithDetectedIndent()},
+		lines: []string{" a", "-b", "+c"},
+	}, {
+		name:  "combined",
+		input: "diff --cc f\n--- a/f\n+++ b/f\n@@@ -1,2 -1,2 +1,2 @@@\n  a\n- b\n ++c\n",
+		lines: []string{"  a", "- b", " ++c"},
+	}} {
+		diff, err := Parse(test.input, append(test.opts, WithLineOffsets())...)
+		require.NoError(t, err, test.name)
+		var lines []string
+		for l := range diff.Files[0].Lines() {
+			lines = append(lines, diff.Raw[l.Offset:l.Offset+l.Length])
+		}
+		require.Equal(t, test.lines, lines, test.name)
+
+		// Both copies of an unchanged line have its offsets.
+		h := diff.Files[0].Hunks[0]
+		require.Equal(t, h.NewRange.Lines[0].Offset, h.OrigRange.Lines[0].Offset, test.name)
+
+		diff, err = Parse(test.input, test.opts...)
+		require.NoError(t, err, test.name)
+		for l := range diff.Files[0].Lines() {
+			require.Zero(t, l.Offset, test.name)
+			require.Zero(t, l.Length, test.name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+// LinePair is a row of a side-by-side diff: a line of the original file
+// beside a line of the new file. Either may be nil.
+type LinePair struct {
+	Orig *DiffLine
+	New  *DiffLine
+}
+
+// Pairs returns the hunk's lines aligned for side-by-side display. Unchanged
+// lines are paired with their copy on the other side, removed lines with a
+// nil New and added lines with a nil Orig, in the order they appear in the
+// diff.
+func (h *DiffHunk) Pairs() []LinePair {
+	pairs := make([]LinePair, 0, len(h.WholeRange.Lines))
+	var next int // index of the next line in OrigRange
+	for _, l := range h.WholeRange.Lines {
+		switch l.Mode {
+		case ADDED:
+			pairs = append(pairs, LinePair{New: l})
+		case REMOVED:
+			pairs = append(pairs, LinePair{Orig: l})
+			next++
+		case UNCHANGED:
+			pair := LinePair{New: l}
+			if next < len(h.OrigRange.Lines) {
+				pair.Orig = h.OrigRange.Lines[next]
+			}
+			pairs = append(pairs, pair)
+			next++
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPairs(t *testing.T) {
+	diff, err := Parse(`diff --git a/a.txt b/a.txt
+index 0a1b2c3..4d5e6f7 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,5 +1,5 @@
+ one
+-two
+-three
++TWO
++THREE
++FOUR
+ five
+-six
+`)
+	require.NoError(t, err)
+
+	type side struct {
+		number  int
+		content string
+	}
+	var rows [][2]*side
+	for _, p := range diff.Files[0].Hunks[0].Pairs() {
+		var row [2]*side
+		if p.Orig != nil {
+			require.NotEqual(t, ADDED, p.Orig.Mode)
+			row[0] = &side{p.Orig.Number, p.Orig.Content}
+		}
+		if p.New != nil {
+			require.NotEqual(t, REMOVED, p.New.Mode)
+			row[1] = &side{p.New.Number, p.New.Content}
+		}
+		rows = append(rows, row)
+	}
+	require.Equal(t, [][2]*side{
+		{{1, "one"}, {1, "one"}},
+		{{2, "two"}, nil},
+		{{3, "three"}, nil},
+		{nil, {2, "TWO"}},
+		{nil, {3, "THREE"}},
+		{nil, {4, "FOUR"}},
+		{{4, "five"}, {5, "five"}},
+		{{5, "six"}, nil},
+	}, rows)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PatchMeta holds the details of a commit from the email headers of a patch
+// made by "git format-patch".
+type PatchMeta struct {
+	// Commit is the hash on the mbox "From" line.
+	Commit string
+	// Author is the patch's author, e.g. "Jesse Meek <jesse@example.com>".
+	Author string
+	// Date is the date the patch was authored.
+	Date time.Time
+	// Subject is the first line of the commit message, without any
+	// "[PATCH]" prefix.
+	Subject string
+	// Message is the rest of the commit message.
+	Message string
+}
+
+// patchSubjectPrefix matches the "[PATCH]" or "[PATCH 1/3]" prefix git adds
+// to subjects.
+var patchSubjectPrefix = regexp.MustCompile(`^\[[^]]*PATCH[^]]*\] *`)
+
+// ParsePatch parses a patch in the format of "git format-patch": an email,
+// optionally starting with an mbox "From" line, whose body holds the commit
+// message, a "---" line, a diffstat and the diff, and ends with a signature.
+// The headers and commit message are returned as PatchMeta, and the diff is
+// parsed as by Parse.
+func ParsePatch(s string, opts ...ParseOption) (*Diff, PatchMeta, error) {
+	var meta PatchMeta
+	if strings.HasPrefix(s, "From ") {
+		line, rest, _ := strings.Cut(s, "\n")
+		if fields := strings.Fields(line); len(fields) > 1 {
+			meta.Commit = fields[1]
+		}
+		s = rest
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(s))
+	if err != nil {
+		return nil, meta, fmt.Errorf("reading patch headers: %w", err)
+	}
+	var decoder mime.WordDecoder
+	if meta.Author, err = decoder.DecodeHeader(msg.Header.Get("From")); err != nil {
+		return nil, meta, fmt.Errorf("invalid From header: %w", err)
+	}
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return nil, meta, fmt.Errorf("invalid Subject header: %w", err)
+	}
+	meta.Subject = patchSubjectPrefix.ReplaceAllString(subject, "")
+	if msg.Header.Get("Date") != "" {
+		if meta.Date, err = msg.Header.Date(); err != nil {
+			return nil, meta, fmt.Errorf("invalid Date header: %w", err)
+		}
+	}
+
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, meta, fmt.Errorf("reading patch body: %w", err)
+	}
+	lines := strings.Split(string(body), "\n")
+
+	// The commit message ends at the "---" line, which is followed by the
+	// diffstat and then the diff.
+	end := len(lines)
+	for i, l := range lines {
+		if l == "---" {
+			end = i
+			break
+		}
+	}
+	meta.Message = strings.TrimSpace(strings.Join(lines[:end], "\n"))
+
+	start := -1
+	for i := end; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "diff ") {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, meta, errors.New("patch has no diff")
+	}
+
+	// Leave out the signature, which follows a "-- " line.
+	last := signatureStart(lines, start)
+
+	diff, err := Parse(strings.Join(lines[start:last], "\n"), opts...)
+	if err != nil {
+		return nil, meta, err
+	}
+	return diff, meta, nil
+}
+
+// signatureStart returns the index of the "-- " line that starts the
+// signature after the diff starting at lines[start], or len(lines) if there
+// is none. A removed line "- " reads as "-- " too, so the lines each hunk's
+// header counts are skipped.
+func signatureStart(lines []string, start int) int {
+	var orig, new int // the lines left in the current hunk
+	for i := start; i < len(lines); i++ {
+		l := lines[i]
+		if orig > 0 || new > 0 {
+			switch {
+			case strings.HasPrefix(l, `\`):
+				// A no-newline marker isn't counted.
+			case strings.HasPrefix(l, "-"):
+				orig--
+			case strings.HasPrefix(l, "+"):
+				new--
+			default:
+				// Mailers may strip the space from blank unchanged
+				// lines.
+				orig--
+				new--
+			}
+			continue
+		}
+		if l == "-- " {
+			return i
+		}
+		if m := hunkHeaderReg.FindStringSubmatch(l); strings.HasPrefix(l, "@@ ") && m != nil {
+			orig, new = rangeLength(m[2]), rangeLength(m[4])
+		}
+	}
+	return len(lines)
+}
+
+// rangeLength returns the length of a hunk range from its header, where it
+// is left out if it is 1.
+func rangeLength(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePatch(t *testing.T) {
+	b, err := os.ReadFile("example.patch")
+	require.NoError(t, err)
+
+	diff, meta, err := ParsePatch(string(b))
+	require.NoError(t, err)
+	require.Equal(t, "cf3c4fbb6e3defb2cd57290e1846b398570b8a09", meta.Commit)
+	require.Equal(t, "Jesse Meek <jesse@example.com>", meta.Author)
+	require.True(t, time.Date(2015, 5, 1, 10, 0, 0, 0, time.FixedZone("", 12*60*60)).Equal(meta.Date))
+	require.Equal(t, "Add c to s.sh and a new file", meta.Subject)
+	require.Equal(t, "The script needs a third line.\n--- not the separator\ndiff --git is mentioned here too", meta.Message)
+
+	require.Len(t, diff.Files, 2)
+	require.Equal(t, "s.sh", diff.Files[0].NewName)
+	require.Equal(t, MODIFIED, diff.Files[0].Mode)
+	require.Equal(t, "t.txt", diff.Files[1].NewName)
+	require.Equal(t, NEW, diff.Files[1].Mode)
+
+	// The signature isn't part of the last hunk.
+	lines := diff.Files[1].Hunks[0].WholeRange.Lines
+	require.Len(t, lines, 1)
+	require.Equal(t, "x", lines[0].Content)
+}
+
+func TestParsePatchHeaders(t *testing.T) {
+	diff, meta, err := ParsePatch(`From: =?UTF-8?q?J=C3=B6rg?= <jorg@example.com>
+Subject: [PATCH 2/3] Fix a long
+ subject
+
+---
+diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1 +1 @@
+-a
++b
+`)
+	require.NoError(t, err)
+	require.Equal(t, "", meta.Commit)
+	require.Equal(t, "Jörg <jorg@example.com>", meta.Author)
+	require.True(t, meta.Date.IsZero())
+	require.Equal(t, "Fix a long subject", meta.Subject)
+	require.Equal(t, "", meta.Message)
+	require.Len(t, diff.Files, 1)
+
+	_, _, err = ParsePatch("From: someone\nSubject: nothing\n\njust text\n")
+	require.EqualError(t, err, "patch has no diff")
+}
+
+func TestParsePatchRemovedSignatureLine(t *testing.T) {
+	// The removed line "- item" reads as the signature separator.
+	patch := `From: someone <someone@example.com>
+Subject: [PATCH] Drop an item
+
+---
+diff --git a/list.md b/list.md
+--- a/list.md
++++ b/list.md
+@@ -1,3 +1,2 @@
+ # List
+-- 
+ end
+`
+	diff, _, err := ParsePatch(patch)
+	require.NoError(t, err)
+	lines := diff.Files[0].Hunks[0].WholeRange.Lines
+	require.Len(t, lines, 3)
+	require.Equal(t, REMOVED, lines[1].Mode)
+	require.Equal(t, "- ", lines[1].Content)
+	require.Equal(t, "end", lines[2].Content)
+
+	// A signature after such a line is still left out.
+	diff, _, err = ParsePatch(patch + "-- \n2.40.0\n")
+	require.NoError(t, err)
+	require.Len(t, diff.Files[0].Hunks[0].WholeRange.Lines, 3)
+	require.Empty(t, diff.Trailing)
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The SGR escapes RenderANSI uses, as git does by default.
+const (
+	ansiReset = "\x1b[m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// RenderOption configures how RenderANSI writes a diff.
+type RenderOption func(*renderOptions)
+
+type renderOptions struct {
+	noColor     bool
+	lineNumbers bool
+	width       int
+}
+
+// NoColor makes RenderANSI write no escapes, for output that isn't a
+// terminal. Control characters are written as they are, as String writes
+// them.
+func NoColor() RenderOption {
+	return func(o *renderOptions) {
+		o.noColor = true
+	}
+}
+
+// LineNumbers makes RenderANSI put a gutter before each line of a hunk,
+// holding its number in the original file and in the new one. A number is
+// left blank for a line that isn't in that file.
+func LineNumbers() RenderOption {
+	return func(o *renderOptions) {
+		o.lineNumbers = true
+	}
+}
+
+// MaxWidth makes RenderANSI cut lines, gutter included, to n characters, such
+// as the width of the terminal. Zero means no limit.
+func MaxWidth(n int) RenderOption {
+	return func(o *renderOptions) {
+		o.width = n
+	}
+}
+
+// RenderANSI writes d to w as String does, colored for a terminal as "git
+// diff" colors it: file headers bold, the ranges of hunk headers cyan,
+// removed lines red and added lines green. Unless NoColor is given, control
+// characters in the diff, such as escapes and carriage returns, are written
+// in caret notation, e.g. "^[", so that they can't disturb the terminal; tabs
+// are kept. Other than that and the options, the text is the same as
+// String's.
+func RenderANSI(w io.Writer, d *Diff, opts ...RenderOption) error {
+	r := &renderer{w: w}
+	for _, opt := range opts {
+		opt(&r.opts)
+	}
+	if r.opts.lineNumbers {
+		r.digits = len(strconv.Itoa(maxLineNumber(d)))
+	}
+	d.print(&printer{w: r, render: r})
+	r.flush()
+	return r.err
+}
+
+// ColorString returns the diff as RenderANSI writes it with opts. With
+// NoColor alone, for output that isn't a terminal, it is the same as String.
+func (d *Diff) ColorString(opts ...RenderOption) string {
+	var b strings.Builder
+	// A strings.Builder doesn't fail.
+	_ = RenderANSI(&b, d, opts...)
+	return b.String()
+}
+
+// maxLineNumber returns the highest line number in d.
+func maxLineNumber(d *Diff) int {
+	var n int
+	for _, f := range d.Files {
+		for _, h := range f.Hunks {
+			n = max(n, h.OrigRange.Start+len(h.OrigRange.Lines), h.NewRange.Start+len(h.NewRange.Lines))
+		}
+	}
+	return n
+}
+
+// lineKind is what a line printer writes is, for the renderer to style it.
+type lineKind int
+
+const (
+	plainLine lineKind = iota
+	fileHeaderLine
+	hunkHeaderLine
+	contextLine
+	addedLine
+	removedLine
+)
+
+// renderer collects the text printer writes into lines and writes each out
+// styled by its kind.
+type renderer struct {
+	w    io.Writer
+	opts renderOptions
+	err  error
+
+	// digits is the width of each number in the gutter.
+	digits int
+
+	// kind, orig and new describe the line being collected, as set by
+	// printer.mark.
+	kind      lineKind
+	orig, new int
+	buf       []byte
+}
+
+func (r *renderer) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			r.buf = append(r.buf, b...)
+			break
+		}
+		r.buf = append(r.buf, b[:i]...)
+		b = b[i+1:]
+		r.writeLine(true)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return n, nil
+}
+
+// flush writes out a last line that has no newline.
+func (r *renderer) flush() {
+	if len(r.buf) > 0 {
+		r.writeLine(false)
+	}
+}
+
+func (r *renderer) writeLine(newline bool) {
+	var b strings.Builder
+	width := r.opts.width
+	if r.opts.lineNumbers && r.kind >= contextLine {
+		gutter := r.number(r.orig) + " " + r.number(r.new) + " "
+		b.WriteString(gutter)
+		width -= len(gutter)
+	}
+	line := string(r.buf)
+	if !r.opts.noColor {
+		line = caretNotation(line)
+	}
+	if r.opts.width > 0 {
+		line = truncate(line, max(width, 0))
+	}
+
+	color := ""
+	if !r.opts.noColor {
+		switch r.kind {
+		case fileHeaderLine:
+			color = ansiBold
+		case hunkHeaderLine:
+			// Only the ranges are colored, not the section heading.
+			marker := line[:len(line)-len(strings.TrimLeft(line, "@"))]
+			if end := strings.Index(line[len(marker):], marker); marker != "" && end >= 0 {
+				end += 2 * len(marker)
+				b.WriteString(ansiCyan + line[:end] + ansiReset)
+				line = line[end:]
+			}
+		case addedLine:
+			color = ansiGreen
+		case removedLine:
+			color = ansiRed
+		}
+	}
+	if color != "" && line != "" {
+		b.WriteString(color + line + ansiReset)
+	} else {
+		b.WriteString(line)
+	}
+	if newline {
+		b.WriteByte('\n')
+	}
+	r.buf = r.buf[:0]
+
+	if r.err == nil {
+		_, r.err = io.WriteString(r.w, b.String())
+	}
+}
+
+// number formats n for the gutter, blank if it is 0.
+func (r *renderer) number(n int) string {
+	if n == 0 {
+		return strings.Repeat(" ", r.digits)
+	}
+	s := strconv.Itoa(n)
+	return strings.Repeat(" ", r.digits-len(s)) + s
+}
+
+// caretNotation replaces the control characters in s, other than tabs, with
+// their caret notation, e.g. "^[" for an escape.
+func caretNotation(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == 0x7f:
+			b.WriteString("^?")
+		case c < 0x20 && c != '\t':
+			b.WriteByte('^')
+			b.WriteByte(c + '@')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// truncate cuts s to n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
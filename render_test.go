@@ -0,0 +1,114 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var sgrReg = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func renderANSI(t *testing.T, d *Diff, opts ...RenderOption) string {
+	var b bytes.Buffer
+	require.NoError(t, RenderANSI(&b, d, opts...))
+	return b.String()
+}
+
+func TestRenderANSI(t *testing.T) {
+	diff, err := Parse(`diff --git a/f b/f
+index 1111111..2222222 100644
+--- a/f
++++ b/f
+@@ -1,3 +1,3 @@ func main() {
+ a
+-b
++c
+ d
+`)
+	require.NoError(t, err)
+	require.Equal(t, "\x1b[1mdiff --git a/f b/f\x1b[m\n"+
+		"\x1b[1mindex 1111111..2222222 100644\x1b[m\n"+
+		"\x1b[1m--- a/f\x1b[m\n"+
+		"\x1b[1m+++ b/f\x1b[m\n"+
+		"\x1b[36m@@ -1,3 +1,3 @@\x1b[m func main() {\n"+
+		" a\n"+
+		"\x1b[31m-b\x1b[m\n"+
+		"\x1b[32m+c\x1b[m\n"+
+		" d\n", renderANSI(t, diff))
+
+	// Without the escapes, the text is String's.
+	for _, d := range []*Diff{setup(t), diff} {
+		require.Equal(t, d.String(), sgrReg.ReplaceAllString(renderANSI(t, d), ""))
+		require.Equal(t, d.String(), renderANSI(t, d, NoColor()))
+	}
+
+	combined, err := Parse(mergeOther)
+	require.NoError(t, err)
+	require.Equal(t, combined.String(), renderANSI(t, combined, NoColor()))
+}
+
+func TestRenderANSIControlCharacters(t *testing.T) {
+	diff, err := Parse("diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-\x1b[2Jx\r\n+\ty\x7f\n")
+	require.NoError(t, err)
+	require.Equal(t, "\x1b[1mdiff --git a/f b/f\x1b[m\n\x1b[1m--- a/f\x1b[m\n\x1b[1m+++ b/f\x1b[m\n\x1b[36m@@ -1 +1 @@\x1b[m\n"+
+		"\x1b[31m-^[[2Jx^M\x1b[m\n\x1b[32m+\ty^?\x1b[m\n", renderANSI(t, diff))
+
+	// Without color, nothing is escaped.
+	require.Equal(t, diff.String(), renderANSI(t, diff, NoColor()))
+}
+
+func TestRenderANSILineNumbers(t *testing.T) {
+	diff, err := Parse(`diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -9,3 +9,3 @@
+ a
+-b
++c
+ d
+\ No newline at end of file
+`)
+	require.NoError(t, err)
+	require.Equal(t, `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -9,3 +9,3 @@
+ 9  9  a
+10    -b
+   10 +c
+11 11  d
+      \ No newline at end of file
+`, renderANSI(t, diff, NoColor(), LineNumbers()))
+}
+
+func TestRenderANSIMaxWidth(t *testing.T) {
+	diff, err := Parse("diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@ heading\n-abcdefgh\n+ä€ß\n")
+	require.NoError(t, err)
+	require.Equal(t, "diff --git\n--- a/f\n+++ b/f\n@@ -1 +1 @\n-abcdefgh\n+ä€ß\n", renderANSI(t, diff, NoColor(), MaxWidth(10)))
+	require.Equal(t, "diff \n--- a\n+++ b\n@@ -1\n-abcd\n+ä€ß\n", renderANSI(t, diff, NoColor(), MaxWidth(5)))
+	require.Contains(t, renderANSI(t, diff, MaxWidth(3)), "\x1b[32m+ä€\x1b[m\n")
+
+	// The gutter counts toward the width.
+	require.Contains(t, renderANSI(t, diff, NoColor(), LineNumbers(), MaxWidth(7)), "\n1   -ab\n  1 +ä€\n")
+}
+
+func TestColorString(t *testing.T) {
+	diff := setup(t)
+	require.Equal(t, renderANSI(t, diff), diff.ColorString())
+	require.Contains(t, diff.ColorString(), ansiGreen+"+")
+	require.Contains(t, diff.ColorString(), ansiRed+"-")
+	require.Contains(t, diff.ColorString(), ansiCyan+"@@ ")
+	require.Equal(t, diff.String(), diff.ColorString(NoColor()))
+	require.Equal(t, renderANSI(t, diff, LineNumbers()), diff.ColorString(LineNumbers()))
+
+	crlf, err := Parse("diff --git a/f b/f\r\n--- a/f\r\n+++ b/f\r\n@@ -1 +1 @@\r\n-a\r\n+b\r\n")
+	require.NoError(t, err)
+	require.Equal(t, crlf.String(), crlf.ColorString(NoColor()))
+	require.Contains(t, crlf.String(), "+b\r\n")
+	require.Contains(t, crlf.ColorString(), ansiGreen+"+b^M"+ansiReset+"\n")
+}
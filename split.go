@@ -0,0 +1,19 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+// SplitByFile returns a diff for each file in d, in order, holding a copy of
+// that file alone. Each diff's Raw is regenerated from its file, so it can be
+// written out or applied on its own, and changing one leaves d and the others
+// as they were. Files without hunks, such as renames, binary files and mode
+// changes, get a diff too.
+func (d *Diff) SplitByFile() []*Diff {
+	diffs := make([]*Diff, 0, len(d.Files))
+	for _, f := range d.Files {
+		split := &Diff{PullID: d.PullID, Files: []*DiffFile{f.Clone()}}
+		split.Raw = split.String()
+		diffs = append(diffs, split)
+	}
+	return diffs
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitByFile(t *testing.T) {
+	diff := setup(t)
+	splits := diff.SplitByFile()
+	require.Len(t, splits, len(diff.Files))
+
+	var joined string
+	for i, split := range splits {
+		require.Len(t, split.Files, 1)
+		requireEquivalent(t, &Diff{Files: []*DiffFile{diff.Files[i]}}, split)
+		require.True(t, diff.Files[i] != split.Files[0])
+		require.Equal(t, split.String(), split.Raw)
+		joined += split.Raw
+
+		parsed, err := Parse(split.Raw)
+		require.NoError(t, err)
+		requireEquivalent(t, split, parsed)
+	}
+	require.Equal(t, diff.String(), joined)
+
+	// Changing one split leaves the diff and the other splits alone.
+	line := splits[0].Files[0].Hunks[0].WholeRange.Lines[0]
+	content := line.Content
+	line.Content = "changed"
+	require.Equal(t, content, diff.Files[0].Hunks[0].WholeRange.Lines[0].Content)
+	splits[0].Files[0].Hunks = nil
+	require.NotEmpty(t, diff.Files[0].Hunks)
+}
+
+func TestSplitByFileWithoutHunks(t *testing.T) {
+	input := `diff --git a/old b/new
+similarity index 100%
+rename from old
+rename to new
+diff --git a/bin b/bin
+index 1111111..2222222 100644
+Binary files a/bin and b/bin differ
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`
+	diff, err := Parse(input)
+	require.NoError(t, err)
+	splits := diff.SplitByFile()
+	require.Len(t, splits, 3)
+	for i, split := range splits {
+		parsed, err := Parse(split.Raw)
+		require.NoError(t, err)
+		require.Len(t, parsed.Files, 1)
+		require.Equal(t, diff.Files[i].Mode, parsed.Files[0].Mode)
+		require.Equal(t, diff.Files[i].NewName, parsed.Files[0].NewName)
+	}
+	require.Equal(t, input, splits[0].Raw+splits[1].Raw+splits[2].Raw)
+}
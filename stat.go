@@ -0,0 +1,269 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// statWidth is the width git gives a diffstat when it isn't writing to a
+// terminal.
+const statWidth = 80
+
+// Stat returns a summary of the diff in the format of "git diff --stat": a
+// line per file with its name, the number of lines changed and a bar of +
+// and - scaled to fit, followed by the totals. Binary files are shown as
+// "Bin", as the diff doesn't hold their sizes. Names too long to fit keep
+// their start and end with "..." in the middle, where git would keep only
+// the end.
+func (d *Diff) Stat() string {
+	type fileStat struct {
+		name           string
+		added, deleted int
+		binary         bool
+	}
+
+	var stats []fileStat
+	var maxLen, maxChange, numberWidth int
+	var insertions, deletions int
+	for _, f := range d.Files {
+		s := fileStat{
+			name:    f.statName(),
+			added:   f.Additions(),
+			deleted: f.Deletions(),
+			binary:  f.IsBinary,
+		}
+		stats = append(stats, s)
+		insertions += s.added
+		deletions += s.deleted
+
+		if l := utf8.RuneCountInString(s.name); l > maxLen {
+			maxLen = l
+		}
+		if s.binary {
+			// Line up the change counts with "Bin".
+			numberWidth = 3
+		} else if c := s.added + s.deleted; c > maxChange {
+			maxChange = c
+		}
+	}
+	if w := len(strconv.Itoa(maxChange)); w > numberWidth {
+		numberWidth = w
+	}
+
+	// Share the width between the names and the graph the way git does:
+	// if both don't fit, the graph gets up to 3/8 of the width.
+	nameWidth, graphWidth := maxLen, maxChange
+	if nameWidth+numberWidth+6+graphWidth > statWidth {
+		if graphWidth > statWidth*3/8-numberWidth-6 {
+			graphWidth = statWidth*3/8 - numberWidth - 6
+			if graphWidth < 6 {
+				graphWidth = 6
+			}
+		}
+		if nameWidth > statWidth-numberWidth-6-graphWidth {
+			nameWidth = statWidth - numberWidth - 6 - graphWidth
+		} else {
+			graphWidth = statWidth - numberWidth - 6 - nameWidth
+		}
+	}
+
+	var b strings.Builder
+	for _, s := range stats {
+		name := truncateMiddle(s.name, nameWidth)
+		padding := nameWidth - utf8.RuneCountInString(name)
+		if padding < 0 {
+			padding = 0
+		}
+		fmt.Fprintf(&b, " %s%s | ", name, strings.Repeat(" ", padding))
+
+		if s.binary {
+			fmt.Fprintf(&b, "%*s\n", numberWidth, "Bin")
+			continue
+		}
+
+		add, del := s.added, s.deleted
+		if graphWidth <= maxChange {
+			total := scaleLinear(add+del, graphWidth, maxChange)
+			if total < 2 && add > 0 && del > 0 {
+				total = 2
+			}
+			if add < del {
+				add = scaleLinear(add, graphWidth, maxChange)
+				del = total - add
+			} else {
+				del = scaleLinear(del, graphWidth, maxChange)
+				add = total - del
+			}
+		}
+		fmt.Fprintf(&b, "%*d", numberWidth, s.added+s.deleted)
+		if s.added+s.deleted > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(strings.Repeat("+", add))
+		b.WriteString(strings.Repeat("-", del))
+		b.WriteString("\n")
+	}
+	b.WriteString(statSummary(len(stats), insertions, deletions))
+	return b.String()
+}
+
+// DiffTotals holds the size of a diff, as counted by Diff.Totals.
+type DiffTotals struct {
+	// Files is the number of files changed, as "git diff --shortstat"
+	// counts them.
+	Files int
+
+	// NewFiles, DeletedFiles, RenamedFiles and CopiedFiles count the files
+	// by Mode, and BinaryFiles those that are binary, whatever their Mode.
+	NewFiles     int
+	DeletedFiles int
+	RenamedFiles int
+	CopiedFiles  int
+	BinaryFiles  int
+
+	// Insertions and Deletions are the number of lines added and removed.
+	Insertions int
+	Deletions  int
+}
+
+// String returns the totals as "git diff --shortstat" shows them, e.g.
+// " 3 files changed, 10 insertions(+), 2 deletions(-)", with a newline.
+func (t DiffTotals) String() string {
+	return statSummary(t.Files, t.Insertions, t.Deletions)
+}
+
+// Totals counts the files and lines of the diff in a single pass over
+// Files, so it reflects any changes made to them since it was parsed.
+func (d *Diff) Totals() DiffTotals {
+	var t DiffTotals
+	for _, f := range d.Files {
+		t.Files++
+		switch f.Mode {
+		case NEW:
+			t.NewFiles++
+		case DELETED:
+			t.DeletedFiles++
+		case RENAMED:
+			t.RenamedFiles++
+		case COPIED:
+			t.CopiedFiles++
+		}
+		if f.IsBinary {
+			t.BinaryFiles++
+		}
+		for _, h := range f.Hunks {
+			for _, l := range h.WholeRange.Lines {
+				switch l.Mode {
+				case ADDED:
+					t.Insertions++
+				case REMOVED:
+					t.Deletions++
+				}
+			}
+		}
+	}
+	return t
+}
+
+// scaleLinear scales n, out of max, to fit within width, keeping non-zero
+// values visible.
+func scaleLinear(n, width, max int) int {
+	if n == 0 {
+		return 0
+	}
+	return 1 + n*(width-1)/max
+}
+
+// statSummary returns the last line of a diffstat.
+func statSummary(files, insertions, deletions int) string {
+	if files == 0 {
+		return " 0 files changed\n"
+	}
+	s := fmt.Sprintf(" %d %s changed", files, plural(files, "file", "files"))
+	if insertions > 0 || deletions == 0 {
+		s += fmt.Sprintf(", %d %s(+)", insertions, plural(insertions, "insertion", "insertions"))
+	}
+	if deletions > 0 || insertions == 0 {
+		s += fmt.Sprintf(", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+	return s + "\n"
+}
+
+// truncateMiddle cuts name to width characters, if it is longer, by replacing
+// its middle with "...".
+func truncateMiddle(name string, width int) string {
+	runes := []rune(name)
+	if len(runes) <= width {
+		return name
+	}
+	keep := max(width-3, 0)
+	head := keep / 2
+	return string(runes[:head]) + "..." + string(runes[len(runes)-(keep-head):])
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// statName returns the name of the file as shown in a diffstat, which for
+// renames shows both names with their common prefix and suffix factored
+// out, e.g. "src/{a => b}/x.go".
+func (f *DiffFile) statName() string {
+	switch {
+	case f.Mode == RENAMED || f.Mode == COPIED:
+		return renameStatName(f.OrigName, f.NewName)
+	case f.NewName == "":
+		return quoteFileName(f.OrigName)
+	}
+	return quoteFileName(f.NewName)
+}
+
+func renameStatName(a, b string) string {
+	if quoteFileName(a) != a || quoteFileName(b) != b {
+		return quoteFileName(a) + " => " + quoteFileName(b)
+	}
+
+	// The common prefix, up to and including a slash.
+	var prefix int
+	for i := 0; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+		if a[i] == '/' {
+			prefix = i + 1
+		}
+	}
+
+	// The common suffix, from a slash. It may share the prefix's slash.
+	var suffix int
+	minStart := prefix
+	if prefix > 0 {
+		minStart--
+	}
+	for i, j := len(a), len(b); i >= minStart && j >= minStart; i, j = i-1, j-1 {
+		if i < len(a) && a[i] != b[j] {
+			break
+		}
+		if i < len(a) && a[i] == '/' {
+			suffix = len(a) - i
+		}
+	}
+
+	aMid := len(a) - prefix - suffix
+	bMid := len(b) - prefix - suffix
+	if aMid < 0 {
+		aMid = 0
+	}
+	if bMid < 0 {
+		bMid = 0
+	}
+	if prefix+suffix == 0 {
+		return a + " => " + b
+	}
+	return a[:prefix] + "{" + a[prefix:prefix+aMid] + " => " + b[prefix:prefix+bMid] + "}" + a[len(a)-suffix:]
+}
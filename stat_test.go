@@ -0,0 +1,222 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStat(t *testing.T) {
+	long := "pkg/very/long/directory/name/that/goes/on/and/on/file_with_a_long_name.go"
+	var added strings.Builder
+	for i := 6; i <= 200; i++ {
+		added.WriteString("+" + strconv.Itoa(i) + "\n")
+	}
+	diff, err := Parse(`diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index 01e79c3..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1,3 +0,0 @@
+-1
+-2
+-3
+diff --git a/` + long + ` b/` + long + `
+index f00c965..153af49 100644
+--- a/` + long + `
++++ b/` + long + `
+@@ -1,10 +1,10 @@
+-1
+-2
+-3
+-4
+-5
+-6
+-7
+-8
+-9
++changed
++changed
++changed
++changed
++changed
++changed
++changed
++changed
++changed
+ 10
+diff --git a/small.txt b/small.txt
+index 8a1218a..aa5e3f8 100644
+--- a/small.txt
++++ b/small.txt
+@@ -3,3 +3,198 @@
+ 3
+ 4
+ 5
+` + added.String() + `diff --git a/src/a/x.go b/src/b/x.go
+similarity index 98%
+rename from src/a/x.go
+rename to src/b/x.go
+index 190423f..c7772ba 100644
+--- a/src/a/x.go
++++ b/src/b/x.go
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`)
+	require.NoError(t, err)
+
+	// The output of "git diff -M --stat" for the same change, but for the
+	// long name, which git cuts at the start rather than in the middle.
+	require.Equal(t, ` gone.txt                                           |   3 -
+ pkg/very/long/directory...file_with_a_long_name.go |  18 +-
+ small.txt                                          | 195 +++++++++++++++++++++
+ src/{a => b}/x.go                                  |   2 +-
+ 4 files changed, 205 insertions(+), 13 deletions(-)
+`, diff.Stat())
+}
+
+func TestStatSmall(t *testing.T) {
+	diff := setup(t)
+	require.Equal(t, ` file1   | 2 +-
+ file2   | 4 ----
+ file3   | 4 ----
+ file4   | 1 +
+ newname | 4 ++++
+ symlink | 1 -
+ 6 files changed, 6 insertions(+), 10 deletions(-)
+`, diff.Stat())
+
+	diff, err := Parse(`diff --git a/img.bin b/img.bin
+index 8352675..ef2caff 100644
+Binary files a/img.bin and b/img.bin differ
+diff --git a/old b/new
+similarity index 100%
+rename from old
+rename to new
+`)
+	require.NoError(t, err)
+	require.Equal(t, ` img.bin    | Bin
+ old => new |   0
+ 2 files changed, 0 insertions(+), 0 deletions(-)
+`, diff.Stat())
+
+	require.Equal(t, " 0 files changed\n", (&Diff{}).Stat())
+}
+
+func TestRenameStatName(t *testing.T) {
+	for _, test := range []struct {
+		orig, new, expected string
+	}{
+		{"a.go", "b.go", "a.go => b.go"},
+		{"src/a/x.go", "src/b/x.go", "src/{a => b}/x.go"},
+		{"src/x.go", "src/sub/x.go", "src/{ => sub}/x.go"},
+		{"a/x.go", "b/x.go", "{a => b}/x.go"},
+		{"dir/a.go", "dir/b.go", "dir/{a.go => b.go}"},
+	} {
+		require.Equal(t, test.expected, renameStatName(test.orig, test.new))
+	}
+}
+
+func TestDiffTotals(t *testing.T) {
+	// Produced by "git diff -M", for which "git diff -M --shortstat" gives
+	// the line checked below.
+	diff, err := Parse(`diff --git a/added.txt b/added.txt
+new file mode 100644
+index 0000000..8ba3a16
+--- /dev/null
++++ b/added.txt
+@@ -0,0 +1 @@
++n
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index b77b4eb..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-x
+-y
+diff --git a/img.bin b/img.bin
+index 88768ef..3e3315e 100644
+Binary files a/img.bin and b/img.bin differ
+diff --git a/keep.txt b/keep.txt
+old mode 100644
+new mode 100755
+index f9d9a01..007f726
+--- a/keep.txt
++++ b/keep.txt
+@@ -1,7 +1,8 @@
+ a
+-b
++B
+ c
+ d
+ e
+ f
+ g
++h
+diff --git a/old.txt b/new.txt
+similarity index 87%
+rename from old.txt
+rename to new.txt
+index 01f84f8..19ea3a0 100644
+--- a/old.txt
++++ b/new.txt
+@@ -7,4 +7,4 @@ l6
+ l7
+ l8
+ l9
+-l10
++L10
+`)
+	require.NoError(t, err)
+
+	totals := diff.Totals()
+	require.Equal(t, DiffTotals{
+		Files:        5,
+		NewFiles:     1,
+		DeletedFiles: 1,
+		RenamedFiles: 1,
+		BinaryFiles:  1,
+		Insertions:   4,
+		Deletions:    4,
+	}, totals)
+	require.Equal(t, " 5 files changed, 4 insertions(+), 4 deletions(-)\n", totals.String())
+	require.True(t, strings.HasSuffix(diff.Stat(), totals.String()))
+
+	// The totals follow changes to Files.
+	diff.Files = diff.Files[:2]
+	require.Equal(t, DiffTotals{Files: 2, NewFiles: 1, DeletedFiles: 1, Insertions: 1, Deletions: 2}, diff.Totals())
+	require.Equal(t, " 0 files changed\n", (&Diff{}).Totals().String())
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		width    int
+		expected string
+	}{
+		{"short.go", 10, "short.go"},
+		{"exactly.go", 10, "exactly.go"},
+		{"a/long/path/to/file.go", 10, "a/l...e.go"},
+		{"a/long/path/to/file.go", 11, "a/lo...e.go"},
+		{"ünïcödé/nämé.go", 9, "ünï....go"},
+		{"abcdef", 3, "..."},
+	} {
+		got := truncateMiddle(test.name, test.width)
+		require.Equal(t, test.expected, got)
+		require.True(t, utf8.RuneCountInString(got) <= max(test.width, 3), got)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrInvalidDiff is wrapped by each of the errors Validate returns.
+var ErrInvalidDiff = errors.New("invalid diff")
+
+// Validate checks that the diff is consistent with itself, as Parse leaves
+// it, which edits such as removing hunks or building a diff by hand may
+// break. It checks that:
+//
+//   - each file has the names its mode needs, and a deleted file adds no
+//     lines and a new file removes none;
+//   - the hunks of a file are in order and don't overlap;
+//   - each range's Length is the number of its lines, which are numbered
+//     from Start on, and hold lines of the right modes;
+//   - the lines' positions increase through each file.
+//
+// Every problem found is reported, joined with errors.Join, each wrapping
+// ErrInvalidDiff and saying where it is, as DiffAgainst does, e.g. "file 2,
+// hunk 1, orig range: length is 3, has 2 lines". Validate returns nil if
+// there are none.
+func (d *Diff) Validate() error {
+	v := &validator{}
+	for i, f := range d.Files {
+		v.validateFile(fmt.Sprintf("file %d", i+1), f)
+	}
+	return errors.Join(v.errs...)
+}
+
+// validator collects the problems found in a diff.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) errorf(where, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%w: %s: %s", ErrInvalidDiff, where, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) validateFile(where string, f *DiffFile) {
+	if _, ok := fileModeNames[f.Mode]; !ok {
+		v.errorf(where, "unknown mode %v", f.Mode)
+	}
+	if f.Mode != NEW && f.OrigName == "" {
+		v.errorf(where, "%v file has no OrigName", f.Mode)
+	}
+	if f.Mode != DELETED && f.NewName == "" {
+		v.errorf(where, "%v file has no NewName", f.Mode)
+	}
+
+	var lastPos int
+	for i, h := range f.Hunks {
+		hunkWhere := fmt.Sprintf("%s, hunk %d", where, i+1)
+		if i > 0 && !hunkFollows(f.Hunks[i-1], h) {
+			v.errorf(hunkWhere, "starts before hunk %d ends", i)
+		}
+		if len(h.ParentRanges) > 0 {
+			for p, r := range h.ParentRanges {
+				v.validateRange(hunkWhere, fmt.Sprintf("parent %d", p+1), r)
+			}
+		} else {
+			v.validateRange(hunkWhere, "orig", h.OrigRange, REMOVED, UNCHANGED)
+		}
+		v.validateRange(hunkWhere, "new", h.NewRange, ADDED, UNCHANGED)
+
+		for j, l := range h.WholeRange.Lines {
+			lineWhere := fmt.Sprintf("%s, whole line %d", hunkWhere, j+1)
+			switch {
+			case f.Mode == DELETED && l.Mode != REMOVED:
+				v.errorf(lineWhere, "deleted file has %v line", l.Mode)
+			case f.Mode == NEW && l.Mode != ADDED:
+				v.errorf(lineWhere, "new file has %v line", l.Mode)
+			}
+			if l.Position <= lastPos {
+				v.errorf(lineWhere, "position %d is not after %d", l.Position, lastPos)
+			}
+			lastPos = l.Position
+		}
+	}
+}
+
+// validateRange checks the range named name, whose lines must have one of
+// modes if any are given.
+func (v *validator) validateRange(where, name string, r DiffRange, modes ...DiffLineMode) {
+	where += ", " + name + " range"
+	if r.Length != len(r.Lines) {
+		v.errorf(where, "length is %d, has %d lines", r.Length, len(r.Lines))
+	}
+	for i, l := range r.Lines {
+		if l.Number != r.Start+i {
+			v.errorf(where, "line %d is numbered %d, not %d", i+1, l.Number, r.Start+i)
+		}
+		if len(modes) > 0 && !slices.Contains(modes, l.Mode) {
+			v.errorf(where, "line %d is %v", i+1, l.Mode)
+		}
+	}
+}
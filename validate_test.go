@@ -0,0 +1,68 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	diff := setup(t)
+	require.NoError(t, diff.Validate())
+	require.NoError(t, (&Diff{}).Validate())
+
+	for _, input := range []string{
+		mergeRename,
+		mergeEdit,
+		"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n@@ -10 +10,0 @@\n-j\n",
+		"diff --cc f\n--- a/f\n+++ b/f\n@@@ -1,2 -1,2 +1,2 @@@\n  a\n- b\n -c\n++d\n",
+	} {
+		parsed, err := Parse(input)
+		require.NoError(t, err)
+		require.NoError(t, parsed.Validate(), input)
+	}
+
+	// Filtering hunks keeps the diff valid.
+	require.NoError(t, diff.FilterHunks(func(*DiffFile, *DiffHunk) bool { return false }, FilterOptions{}).Validate())
+}
+
+func TestValidateProblems(t *testing.T) {
+	diff := setup(t)
+	file1 := diff.Files[0]
+	hunk := file1.Hunks[0]
+	hunk.NewRange.Length = 5
+	hunk.OrigRange.Lines[1].Number = 7
+	hunk.WholeRange.Lines[3].Position = 1
+	diff.Files[1].Hunks[0].OrigRange.Lines[0].Mode = ADDED
+	diff.Files[2].Mode = NEW
+	diff.Files[2].NewName = ""
+	diff.Files[3].OrigName = ""
+	diff.Files[3].Mode = MODIFIED
+
+	err := diff.Validate()
+	require.True(t, errors.Is(err, ErrInvalidDiff))
+	require.Equal(t, []string{
+		"invalid diff: file 1, hunk 1, orig range: line 2 is numbered 7, not 2",
+		"invalid diff: file 1, hunk 1, new range: length is 5, has 4 lines",
+		"invalid diff: file 1, hunk 1, whole line 4: position 1 is not after 3",
+		"invalid diff: file 2, hunk 1, orig range: line 1 is added",
+		"invalid diff: file 2, hunk 1, whole line 1: deleted file has added line",
+		"invalid diff: file 3: new file has no NewName",
+		"invalid diff: file 3, hunk 1, whole line 1: new file has removed line",
+		"invalid diff: file 3, hunk 1, whole line 2: new file has removed line",
+		"invalid diff: file 3, hunk 1, whole line 3: new file has removed line",
+		"invalid diff: file 3, hunk 1, whole line 4: new file has removed line",
+		"invalid diff: file 4: modified file has no OrigName",
+	}, strings.Split(err.Error(), "\n"))
+}
+
+func TestValidateHunkOrder(t *testing.T) {
+	diff, err := Parse("diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -10 +10 @@\n-a\n+b\n@@ -2 +2 @@\n-c\n+d\n")
+	require.NoError(t, err)
+	require.EqualError(t, diff.Validate(), "invalid diff: file 1, hunk 2: starts before hunk 1 ends")
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import "strings"
+
+// TrimmedEquals reports whether l and other have the same content once
+// trailing whitespace is trimmed from both, as "git diff
+// --ignore-space-at-eol" compares lines. A carriage return counts as
+// whitespace, so a line differing only in its line ending is equal too.
+func (l *DiffLine) TrimmedEquals(other *DiffLine) bool {
+	return trimTrailingSpace(l.Content) == trimTrailingSpace(other.Content)
+}
+
+// IsWhitespaceOnly reports whether the hunk changes nothing but trailing
+// whitespace: each run of changed lines between unchanged ones removes as
+// many lines as it adds, and the nth line removed TrimmedEquals the nth
+// line added. A hunk that changes no lines is not whitespace only.
+func (h *DiffHunk) IsWhitespaceOnly() bool {
+	var removed, added []*DiffLine
+	var changed bool
+	for _, l := range h.WholeRange.Lines {
+		switch l.Mode {
+		case REMOVED:
+			removed = append(removed, l)
+			changed = true
+		case ADDED:
+			added = append(added, l)
+			changed = true
+		case UNCHANGED:
+			if !trimmedEqual(removed, added) {
+				return false
+			}
+			removed, added = removed[:0], added[:0]
+		}
+	}
+	return changed && trimmedEqual(removed, added)
+}
+
+// trimmedEqual reports whether removed and added hold the same lines, but
+// for trailing whitespace.
+func trimmedEqual(removed, added []*DiffLine) bool {
+	if len(removed) != len(added) {
+		return false
+	}
+	for i := range removed {
+		if !removed[i].TrimmedEquals(added[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func trimTrailingSpace(s string) string {
+	return strings.TrimRight(s, " \t\r\f\v")
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2015 Jesse Meek <https://github.com/waigani>
+// This program is Free Software see LICENSE file for details.
+
+package diffparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrimmedEquals(t *testing.T) {
+	for _, test := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{"x", "x", true},
+		{"x  ", "x", true},
+		{"x\t", "x \r", true},
+		{"x", " x", false},
+		{"x y", "x  y", false},
+		{"x", "y", false},
+	} {
+		a, b := &DiffLine{Content: test.a}, &DiffLine{Content: test.b}
+		require.Equal(t, test.equal, a.TrimmedEquals(b), "%q %q", test.a, test.b)
+		require.Equal(t, test.equal, b.TrimmedEquals(a), "%q %q", test.b, test.a)
+	}
+}
+
+func TestIsWhitespaceOnly(t *testing.T) {
+	for _, test := range []struct {
+		hunk       string
+		whitespace bool
+	}{{
+		hunk:       "@@ -1,5 +1,5 @@\n a\n-b  \n-c\t\n+b\n+c\n d\n-e \n+e\n",
+		whitespace: true,
+	}, {
+		hunk:       "@@ -1,3 +1,3 @@\n a\n-b  \n+B\n c\n",
+		whitespace: false,
+	}, {
+		// Same content, but a line is removed.
+		hunk:       "@@ -1,3 +1,2 @@\n a\n-b \n-b\n+b\n",
+		whitespace: false,
+	}, {
+		hunk:       "@@ -1,2 +1,3 @@\n a\n+\n b\n",
+		whitespace: false,
+	}, {
+		hunk:       "@@ -1,2 +1,2 @@\n-  a\n+a\n b\n",
+		whitespace: false,
+	}} {
+		diff, err := Parse("diff --git a/f b/f\n--- a/f\n+++ b/f\n" + test.hunk)
+		require.NoError(t, err)
+		require.Equal(t, test.whitespace, diff.Files[0].Hunks[0].IsWhitespaceOnly(), test.hunk)
+	}
+	require.False(t, (&DiffHunk{}).IsWhitespaceOnly())
+}